@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archive is either the archive index (no Year), a year (no Month) or a month
+// of entries. Archives holds the years of the index and the months of a year,
+// newest first.
+type archive struct {
+	Year     int
+	Month    time.Month
+	Entries  []*entry
+	Archives []*archive
+	Blog     *blog
+	Modified time.Time
+}
+
+func newArchive(b *blog, entries []*entry) *archive {
+	a := &archive{Entries: entries, Blog: b}
+	a.Modified = findLatestModified(a.Entries)
+
+	for _, e := range entries {
+		posted := e.Posted.In(b.Config.Location())
+		y := a.child(posted.Year(), 0)
+		y.Entries = append(y.Entries, e)
+
+		m := y.child(posted.Year(), posted.Month())
+		m.Entries = append(m.Entries, e)
+	}
+
+	for _, y := range a.Archives {
+		y.Modified = findLatestModified(y.Entries)
+		for _, m := range y.Archives {
+			m.Modified = findLatestModified(m.Entries)
+		}
+	}
+
+	return a
+}
+
+// child expects to be called with entries sorted by date.
+func (a *archive) child(year int, month time.Month) *archive {
+	if len(a.Archives) > 0 {
+		last := a.Archives[len(a.Archives)-1]
+		if last.Year == year && last.Month == month {
+			return last
+		}
+	}
+
+	c := &archive{Year: year, Month: month, Entries: []*entry{}, Blog: a.Blog}
+	a.Archives = append(a.Archives, c)
+	return c
+}
+
+func (a *archive) IsIndex() bool {
+	return a.Year == 0
+}
+
+func (a *archive) IsYear() bool {
+	return a.Year != 0 && a.Month == 0
+}
+
+func (a *archive) IsMonth() bool {
+	return a.Month != 0
+}
+
+func (a *archive) Count() int {
+	return len(a.Entries)
+}
+
+func (a *archive) Name() string {
+	switch {
+	case a.IsIndex():
+		return "archive"
+	case a.IsYear():
+		return fmt.Sprintf("%04d", a.Year)
+	default:
+		return fmt.Sprintf("%04d-%02d", a.Year, a.Month)
+	}
+}
+
+func (a *archive) dirs() []string {
+	switch {
+	case a.IsIndex():
+		return []string{"archive"}
+	case a.IsYear():
+		return []string{"archive", fmt.Sprintf("%04d", a.Year)}
+	default:
+		return []string{"archive", fmt.Sprintf("%04d", a.Year), fmt.Sprintf("%02d", a.Month)}
+	}
+}
+
+func (a *archive) URL() string {
+	args := append([]string{a.Blog.BaseURL}, a.dirs()...)
+	return urlJoin(append(args, a.HTMLFileName())...)
+}
+
+func (a *archive) RelativeURL() string {
+	args := append([]string{a.Blog.rootPath()}, a.dirs()...)
+	return urlJoin(append(args, a.HTMLFileName())...)
+}
+
+// RelativeRoot is the path from the archive page to the output root, for
+// templates to refer to other pages relatively.
+func (a *archive) RelativeRoot() string {
+	return strings.Repeat("../", len(a.dirs()))
+}
+
+func (a *archive) HTMLFileName() string {
+	return "index.html"
+}
+
+func (a *archive) collectURLs() []string {
+	urls := []string{a.URL()}
+	for _, c := range a.Archives {
+		urls = append(urls, c.collectURLs()...)
+	}
+	return urls
+}
+
+func (a *archive) renderIndex() error {
+	var err error
+	var buf bytes.Buffer
+
+	err = a.Blog.templates.Archive.ExecuteTemplate(&buf, "archive", a)
+	if err != nil {
+		return fmt.Errorf("failed to execute archive template: %w", err)
+	}
+
+	dir := filepath.Join(append([]string{a.Blog.OutputDirectory}, a.dirs()...)...)
+	err = os.MkdirAll(dir, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create archive directory [%s] err=%w", dir, err)
+	}
+
+	fp := filepath.Join(dir, a.HTMLFileName())
+	err = a.Blog.writeOutput(fp, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	verbose("rendered archive %#v to %#v.", a.Name(), fp)
+
+	for _, c := range a.Archives {
+		err = c.renderIndex()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// asset is a processed stylesheet or script in the output directory: either
+// a synced .css, .js or .scss file or a configured bundle.
+type asset struct {
+	Name      string // slash separated path relative to the output directory
+	File      string // path of the fingerprinted file, or of Name without fingerprinting
+	Integrity string // subresource integrity hash, e.g. "sha384-..."
+
+	Blog *blog
+}
+
+func (a *asset) URL() string {
+	rel, err := filepath.Rel(a.Blog.OutputDirectory, a.File)
+	if err != nil {
+		return urlJoin(a.Blog.BaseURL, a.Name)
+	}
+	return urlJoin(a.Blog.BaseURL, filepath.ToSlash(rel))
+}
+
+func assetMediaType(name string) string {
+	if filepath.Ext(name) == ".js" {
+		return "application/javascript"
+	}
+	return "text/css"
+}
+
+func isAssetSource(fn string) bool {
+	switch filepath.Ext(fn) {
+	case ".css", ".js":
+		return true
+	case ".scss":
+		return !strings.HasPrefix(filepath.Base(fn), "_") // partials are only imported
+	}
+	return false
+}
+
+// readAssetSource returns the contents of a stylesheet or script, compiling
+// scss to css.
+func readAssetSource(fn string) ([]byte, error) {
+	if filepath.Ext(fn) == ".scss" {
+		return compileSCSS(fn)
+	}
+	return os.ReadFile(fn)
+}
+
+// processAssets runs the synced stylesheets and scripts as well as the
+// configured bundles through the asset pipeline: scss compilation,
+// concatenation, minification and fingerprinting.
+func (b *blog) processAssets() error {
+	cfg := b.Config.Assets
+	if cfg == nil {
+		return nil
+	}
+
+	sources := map[string][]string{}
+	walker := func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && pth != b.BaseDirectory && (strings.HasPrefix(info.Name(), ".") || b.isForeignDirectory(pth)) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !isAssetSource(pth) || isGenerated(pth) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.BaseDirectory, pth)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if filepath.Ext(name) == ".scss" {
+			name = strings.TrimSuffix(name, ".scss") + ".css"
+		} else if _, ok := sources[name]; ok {
+			return nil // compiled from scss, e.g. when writing in place
+		}
+		sources[name] = []string{pth}
+		return nil
+	}
+	err := filepath.Walk(b.BaseDirectory, walker)
+	if err != nil {
+		return fmt.Errorf("failed to search for assets: %w", err)
+	}
+
+	for name, fns := range cfg.Bundles {
+		files := []string{}
+		for _, fn := range fns {
+			files = append(files, filepath.Join(b.BaseDirectory, fn))
+		}
+		sources[name] = files
+	}
+
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		a, err := b.processAsset(name, sources[name])
+		if err != nil {
+			return err
+		}
+		b.assets[name] = a
+	}
+	verbose("processed %v assets.", len(b.assets))
+
+	return nil
+}
+
+func (b *blog) processAsset(name string, sources []string) (*asset, error) {
+	var buf bytes.Buffer
+	for i, fn := range sources {
+		byt, err := readAssetSource(fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset %#v: %w", fn, err)
+		}
+		if i > 0 && filepath.Ext(name) == ".js" {
+			buf.WriteString(";\n") // guards against scripts without trailing semicolon
+		}
+		buf.Write(byt)
+		buf.WriteString("\n")
+	}
+	content := buf.Bytes()
+
+	// assets are minified here rather than in writeOutput so that the
+	// integrity hash matches the written file.
+	if b.Config.Assets.Minify || b.Config.Minify {
+		var out bytes.Buffer
+		err := b.minifier.Minify(assetMediaType(name), &out, bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to minify asset %#v: %w", name, err)
+		}
+		content = out.Bytes()
+	}
+
+	sri := sha512.Sum384(content)
+	a := &asset{
+		Name:      name,
+		File:      filepath.Join(b.OutputDirectory, filepath.FromSlash(name)),
+		Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sri[:]),
+		Blog:      b,
+	}
+
+	// the plain name is kept up to date for templates that link it directly,
+	// but a source file is never overwritten if base and output directory
+	// are the same.
+	if len(sources) != 1 || sources[0] != a.File {
+		err := b.writeAsset(a.File, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.Config.Assets.Fingerprint {
+		sum := sha256.Sum256(content)
+		ext := filepath.Ext(a.File)
+		a.File = fmt.Sprintf("%s.%s%s", strings.TrimSuffix(a.File, ext), hex.EncodeToString(sum[:])[:10], ext)
+		err := b.writeAsset(a.File, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (b *blog) writeAsset(fn string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(fn), 0770)
+	if err != nil {
+		return err
+	}
+	err = b.writeOutput(fn, content)
+	if err != nil {
+		return err
+	}
+	verbose("write asset %#v.", fn)
+	return nil
+}
+
+// Asset returns the url of the processed asset with the given name, the
+// fingerprinted url if enabled. Without asset pipeline the plain url of the
+// file is returned.
+func (b *blog) Asset(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if b.Config.Assets == nil {
+		return urlJoin(b.BaseURL, name), nil
+	}
+	a, ok := b.assets[name]
+	if !ok {
+		return "", fmt.Errorf("unknown asset %#v", name)
+	}
+	return a.URL(), nil
+}
+
+// AssetIntegrity returns the subresource integrity hash of the asset with
+// the given name for the integrity attribute of link and script elements.
+func (b *blog) AssetIntegrity(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if b.Config.Assets == nil {
+		return "", fmt.Errorf("asset pipeline is not configured")
+	}
+	a, ok := b.assets[name]
+	if !ok {
+		return "", fmt.Errorf("unknown asset %#v", name)
+	}
+	return a.Integrity, nil
+}
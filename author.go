@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type author struct {
+	ID     string
+	Name   string
+	Bio    string
+	Avatar string
+	Email  string
+	Links  map[string]string
+
+	Entries  []*entry
+	Blog     *blog
+	Modified time.Time
+
+	// configured is false for authors that are only named in front matter.
+	configured bool
+}
+
+func newAuthor(b *blog, id string, ac *authorConfig) *author {
+	a := &author{
+		ID:      id,
+		Name:    ac.Name,
+		Bio:     ac.Bio,
+		Avatar:  ac.Avatar,
+		Email:   ac.Email,
+		Links:   ac.Links,
+		Entries: []*entry{},
+		Blog:    b,
+	}
+	if a.Name == "" {
+		a.Name = id
+	}
+	return a
+}
+
+func (a *author) String() string {
+	return a.Name
+}
+
+func readAuthorsFile(fn string) (map[string]*authorConfig, error) {
+	result := map[string]*authorConfig{}
+
+	bt, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authors file %#v: %w", fn, err)
+	}
+
+	err = json.Unmarshal(bt, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal authors file %#v: %w", fn, err)
+	}
+
+	return result, nil
+}
+
+// findAuthor matches name against the ids and names of known authors, and
+// registers a new author for unknown names.
+func (b *blog) findAuthor(name string) *author {
+	id := tagSlug(name)
+	for _, a := range b.Authors {
+		if strings.EqualFold(a.ID, name) || strings.EqualFold(a.Name, name) || a.ID == id {
+			return a
+		}
+	}
+
+	a := newAuthor(b, id, &authorConfig{Name: name})
+	b.Authors = append(b.Authors, a)
+	return a
+}
+
+func (a *author) URL() string {
+	return urlJoin(a.Blog.BaseURL, "authors", a.HTMLFileName())
+}
+
+func (a *author) RelativeURL() string {
+	return urlJoin(a.Blog.rootPath(), "authors", a.HTMLFileName())
+}
+
+func (a *author) HTMLFileName() string {
+	return fmt.Sprintf("%s.html", a.ID)
+}
+
+func (a *author) renderIndex() error {
+	var err error
+	var buf bytes.Buffer
+
+	err = a.Blog.templates.Author.ExecuteTemplate(&buf, "author", a)
+	if err != nil {
+		return fmt.Errorf("failed to execute author index template: %w", err)
+	}
+
+	authorDir := filepath.Join(a.Blog.OutputDirectory, "authors")
+	err = os.MkdirAll(authorDir, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create authors directory [%s] err=%w", authorDir, err)
+	}
+
+	fp := filepath.Join(authorDir, a.HTMLFileName())
+	err = a.Blog.writeOutput(fp, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write author index file: %w", err)
+	}
+	verbose("rendered index for author %#v to %#v.", a.Name, fp)
+
+	fc := a.Blog.Config.Feed
+	if fc == nil {
+		return nil
+	}
+
+	title := fmt.Sprintf("%s: %s", fc.Title, a.Name)
+	fd := newFeed(fc, title, a.URL(), latest(a.Entries, feedEntryCount))
+	return a.Blog.writeFeeds(fc, fd, authorDir, a.ID)
+}
+
+func sortAuthors(authors []*author) {
+	byID := func(i, j int) bool {
+		return authors[i].ID < authors[j].ID
+	}
+	sort.Slice(authors, byID)
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -13,7 +13,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gorilla/feeds"
+	"github.com/tdewolff/minify/v2"
 )
 
 type blog struct {
@@ -23,13 +23,36 @@ type blog struct {
 	BaseURL         string
 	Config          *config
 
+	// Language is the code of the blog's language, Languages holds the
+	// blogs of all configured languages, starting with the default.
+	Language  string
+	Languages []*blog
+
 	Entries      []*entry
 	DraftEntries []*entry
 	Tops         []*top
 	Groups       []*group
 	Tags         []*tag
-
-	templates *templates
+	Series       []*series
+	Archive      *archive
+	Taxonomies   map[string]*taxonomy
+	Authors      []*author
+	Data         map[string]interface{}
+	Menus        map[string]menu
+
+	templates   *templates
+	gitModTimes map[string]time.Time
+	images      map[string]*processedImage
+	assets      map[string]*asset
+
+	// outputs holds the files written or synced to the output directory,
+	// precompressOutputs only compresses these so that sources are left
+	// alone when writing in place.
+	outputs map[string]bool
+
+	minifier      *minify.M
+	minifiedFiles int
+	minifiedBytes int64
 }
 
 func newBlog(cfg *config) *blog {
@@ -42,6 +65,15 @@ func newBlog(cfg *config) *blog {
 		Entries:         []*entry{},
 		Groups:          []*group{},
 		Tags:            []*tag{},
+		Series:          []*series{},
+		Taxonomies:      map[string]*taxonomy{},
+		Authors:         []*author{},
+		Data:            map[string]interface{}{},
+		Menus:           map[string]menu{},
+		images:          map[string]*processedImage{},
+		assets:          map[string]*asset{},
+		outputs:         map[string]bool{},
+		minifier:        newMinifier(),
 	}
 
 	if b.OutputDirectory == "" {
@@ -51,24 +83,66 @@ func newBlog(cfg *config) *blog {
 	return b
 }
 
-func (b *blog) regenerate() error {
-	fail(b.syncAssets())
+func regenerate(blogs []*blog) error {
+	for _, b := range blogs {
+		fail(b.read())
+	}
+	linkTranslations(blogs)
+	for _, b := range blogs {
+		fail(b.write())
+	}
+	return nil
+}
+
+func (b *blog) read() error {
+	fail(b.processAssets())
 
 	fail(b.readTemplates())
-	fail(b.readEntries())
-	fail(b.readTops())
+	fail(b.readAuthors())
+	fail(b.readData())
+	fail(b.readGitModTimes())
+	entries, pages, err := b.findMarkdownFiles()
+	fail(err)
+	fail(b.readEntries(entries))
+	fail(b.readTops(pages))
 	fail(b.findGroups())
 	fail(b.findTags())
+	fail(b.findSeries())
+	fail(b.findArchives())
+	fail(b.findTaxonomies())
+	fail(b.findAuthors())
+	fail(b.findMenus())
+	fail(b.linkEntries())
+
+	// synced last to not overwrite the outputs of the asset and image
+	// pipelines with their sources.
+	fail(b.syncAssets())
+
+	return nil
+}
+
+func (b *blog) write() error {
+	err := os.MkdirAll(b.OutputDirectory, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory [%s] err=%w", b.OutputDirectory, err)
+	}
 
 	fail(b.writeTops())
 	fail(b.writeEntries())
 	fail(b.writeDraftEntries())
 	fail(b.renderGroups())
 	fail(b.renderTags())
+	fail(b.renderSeries())
+	fail(b.renderArchives())
+	fail(b.renderTaxonomies())
+	fail(b.renderAuthors())
 	fail(b.renderFeed())
 	fail(b.renderMainIndex())
 	fail(b.renderSitemap())
 
+	b.reportMinified()
+	fail(b.precompressOutputs())
+
 	return nil
 }
 
@@ -92,11 +166,39 @@ func (b *blog) syncWalker(sf string, sfi os.FileInfo, err error) error {
 		}
 	}
 
+	if sfi.IsDir() && b.isForeignDirectory(sf) {
+		log.Printf("sync skips output directory: %#v\n", sf)
+		return filepath.SkipDir
+	}
+
+	if !sfi.IsDir() && (!b.isLanguageAsset(sf) || filepath.Base(sf) == captionsFile) {
+		return nil
+	}
+
+	// scss sources and partials are compiled by the asset pipeline.
+	if !sfi.IsDir() && b.Config.Assets != nil && filepath.Ext(sf) == ".scss" {
+		return nil
+	}
+
 	rf, err := filepath.Rel(b.BaseDirectory, sf)
 	if err != nil {
 		return err
 	}
 	tf := filepath.Join(b.OutputDirectory, rf)
+	if b.outputs[tf] {
+		return nil // written by the asset or image pipeline
+	}
+
+	// images are published without EXIF data, e.g. GPS, also by languages
+	// that don't render them.
+	if !sfi.IsDir() && isProcessableImage(sf) && !isGenerated(sf) {
+		si, err := readSourceImage(sf)
+		if err != nil {
+			return err
+		}
+		_, err = b.variant(si, tf, si.width)
+		return err
+	}
 
 	tfi, err := os.Stat(tf)
 	if err != nil {
@@ -115,8 +217,10 @@ func (b *blog) syncWalker(sf string, sfi os.FileInfo, err error) error {
 			log.Printf("sync skips irregular target file: %#v\n", tf)
 			return err
 		}
-		if os.SameFile(sfi, tfi) {
+		if tfi.Size() == sfi.Size() && sameContent(sf, tf) {
 			log.Printf("sync skips unchanged target file: %#v\n", tf)
+			b.outputs[tf] = true
+			return nil
 		}
 	}
 
@@ -143,6 +247,7 @@ func (b *blog) syncWalker(sf string, sfi os.FileInfo, err error) error {
 	}
 
 	log.Printf("sync'd source to target %#v\n", tf)
+	b.outputs[tf] = true
 	return nil
 }
 
@@ -156,7 +261,7 @@ func (b *blog) syncAssets() error {
 
 func (b *blog) readTemplates() error {
 	var err error
-	b.templates, err = readTemplates(b.Config.Templates)
+	b.templates, err = readTemplates(b.Config.Templates, b.templateFuncs())
 	return err
 }
 
@@ -165,6 +270,10 @@ func (b *blog) collectURLs() []string {
 
 	urls = append(urls, b.URL())
 
+	for _, t := range b.Tops {
+		urls = append(urls, t.URL())
+	}
+
 	for _, e := range b.Entries {
 		urls = append(urls, e.URL())
 	}
@@ -173,17 +282,82 @@ func (b *blog) collectURLs() []string {
 		urls = append(urls, g.URL())
 	}
 
+	urls = append(urls, b.TagIndex().URL())
 	for _, t := range b.Tags {
 		urls = append(urls, t.URL())
 	}
 
-	return urls
+	for _, s := range b.Series {
+		urls = append(urls, s.URL())
+	}
+
+	urls = append(urls, b.Archive.collectURLs()...)
+
+	for _, tc := range b.Config.Taxonomies {
+		tx := b.Taxonomies[tc.Name]
+		urls = append(urls, tx.URL())
+		for _, t := range tx.Terms {
+			urls = append(urls, t.URL())
+		}
+	}
+
+	for _, a := range b.Authors {
+		urls = append(urls, a.URL())
+	}
+
+	// a top like index.md has the url of the main index.
+	seen := map[string]bool{}
+	result := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if !seen[u] {
+			seen[u] = true
+			result = append(result, u)
+		}
+	}
+
+	return result
+}
+
+// Blog lets the main template refer to .Blog like all other templates.
+func (b *blog) Blog() *blog {
+	return b
 }
 
 func (b *blog) URL() string {
 	return urlJoin(b.BaseURL, "index.html")
 }
 
+type sitemapURL struct {
+	Loc        string
+	Alternates []*sitemapURL
+	Language   string
+}
+
+// sitemapURLs adds the urls of translations as alternates.
+func (b *blog) sitemapURLs(urls []string) []*sitemapURL {
+	alternates := map[string][]*sitemapURL{}
+	if len(b.Languages) > 0 {
+		for _, l := range b.Languages {
+			alternates[b.URL()] = append(alternates[b.URL()], &sitemapURL{Loc: l.URL(), Language: l.Language})
+		}
+	}
+	for _, e := range b.Entries {
+		if len(e.Translations) == 0 {
+			continue
+		}
+		alternates[e.URL()] = append(alternates[e.URL()], &sitemapURL{Loc: e.URL(), Language: e.Language})
+		for _, t := range e.Translations {
+			alternates[e.URL()] = append(alternates[e.URL()], &sitemapURL{Loc: t.URL(), Language: t.Language})
+		}
+	}
+
+	result := make([]*sitemapURL, 0, len(urls))
+	for _, u := range urls {
+		result = append(result, &sitemapURL{Loc: u, Alternates: alternates[u]})
+	}
+	return result
+}
+
 func (b *blog) renderSitemap() error {
 	if b.Config.SitemapFile == "" {
 		verbose("no sitemap file configured")
@@ -193,11 +367,13 @@ func (b *blog) renderSitemap() error {
 	var buf bytes.Buffer
 	var err error
 
-	xml := `<?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	// the declaration is written separately as html/template would escape it.
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	xml := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
 {{ range . }}    <url>
-        <loc>{{ . }}</loc>
-    </url>
+        <loc>{{ .Loc }}</loc>
+{{ range .Alternates }}        <xhtml:link rel="alternate" hreflang="{{ .Language }}" href="{{ .Loc }}"/>
+{{ end }}    </url>
 {{ end }}</urlset>
 `
 
@@ -207,13 +383,13 @@ func (b *blog) renderSitemap() error {
 	}
 
 	urls := b.collectURLs()
-	err = tmpl.ExecuteTemplate(&buf, "sitemap", urls)
+	err = tmpl.ExecuteTemplate(&buf, "sitemap", b.sitemapURLs(urls))
 	if err != nil {
 		return fmt.Errorf("failed to execute sitemap template: %w", err)
 	}
 
 	fn := filepath.Join(b.OutputDirectory, b.Config.SitemapFile)
-	err = os.WriteFile(fn, buf.Bytes(), 0644)
+	err = b.writeOutput(fn, buf.Bytes())
 	if err != nil {
 		return fmt.Errorf("failed to write %#v: %w", fn, err)
 	}
@@ -229,36 +405,11 @@ func (b *blog) renderFeed() error {
 		return nil
 	}
 
-	fd := &feeds.Feed{
-		Title:       fc.Title,
-		Link:        &feeds.Link{Href: fc.LinkHREF},
-		Description: fc.Description,
-		Author:      &feeds.Author{Name: fc.AuthorName, Email: fc.AuthorEmail},
-		Created:     time.Now(),
-	}
-
-	for _, e := range b.LatestEntries(3) {
-		itm := &feeds.Item{
-			Title:   e.Title,
-			Link:    &feeds.Link{Href: e.URL()},
-			Source:  &feeds.Link{Href: e.URL()},
-			Created: e.Posted,
-			Author:  &feeds.Author{Name: e.Author},
-			Content: string(e.RenderedHTML),
-		}
-		fd.Add(itm)
-	}
+	fd := newFeed(fc, fc.Title, fc.LinkHREF, b.LatestEntries(feedEntryCount))
 
 	if fc.RSSEnabled {
-
 		of := filepath.Join(b.OutputDirectory, "rss.xml")
-		fh, err := os.OpenFile(of, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to open rss file %#v: %w", of, err)
-		}
-
-		w := bufio.NewWriter(fh)
-		err = fd.WriteRss(w)
+		err := b.writeFeedFile(of, fd.WriteRss)
 		if err != nil {
 			return fmt.Errorf("failed to write feed to rss: %w", err)
 		}
@@ -266,13 +417,7 @@ func (b *blog) renderFeed() error {
 
 	if fc.AtomEnabled {
 		of := filepath.Join(b.OutputDirectory, "atom.xml")
-		fh, err := os.OpenFile(of, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to open atom file %#v: %w", of, err)
-		}
-
-		w := bufio.NewWriter(fh)
-		err = fd.WriteAtom(w)
+		err := b.writeFeedFile(of, fd.WriteAtom)
 		if err != nil {
 			return fmt.Errorf("failed to write feed to atom: %w", err)
 		}
@@ -294,19 +439,30 @@ func (b *blog) findTagNames() []string {
 	for tn, _ := range uniq {
 		result = append(result, tn)
 	}
-	sort.Strings(result)
+	bySlug := func(i, j int) bool {
+		return tagSlug(result[i]) < tagSlug(result[j])
+	}
+	sort.Slice(result, bySlug)
 
 	return result
 }
 
 func (b *blog) findTags() error {
+	err := b.mergeTags()
+	if err != nil {
+		return err
+	}
 	tagNames := b.findTagNames()
 
 	b.Tags = make([]*tag, 0, len(tagNames))
 	for _, tn := range tagNames {
+		if tagSlug(tn) == "index" {
+			return fmt.Errorf("tag %#v is reserved for the tag overview", tn)
+		}
 		t := newTag(b, tn)
 		b.Tags = append(b.Tags, t)
 	}
+	weighTags(b.Tags)
 
 	return nil
 }
@@ -318,6 +474,160 @@ func (b *blog) renderTags() error {
 			return err
 		}
 	}
+	return b.TagIndex().renderIndex()
+}
+
+// Tag finds a tag by any of its spellings.
+func (b *blog) Tag(name string) *tag {
+	sl := tagSlug(b.resolveTagAlias(name))
+	for _, t := range b.Tags {
+		if t.Slug == sl {
+			return t
+		}
+	}
+	return nil
+}
+
+func (b *blog) TagIndex() *tagIndex {
+	return &tagIndex{Tags: b.Tags, Blog: b}
+}
+
+func (b *blog) findSeriesNames() []string {
+	uniq := map[string]struct{}{}
+
+	for _, e := range b.Entries {
+		if e.SeriesName != "" {
+			uniq[e.SeriesName] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(uniq))
+	for n := range uniq {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func (b *blog) findSeries() error {
+	names := b.findSeriesNames()
+
+	b.Series = make([]*series, 0, len(names))
+	slugs := map[string]string{}
+	for _, n := range names {
+		sl := slugify(n)
+		if sl == "" {
+			return fmt.Errorf("series %#v needs letters or digits for its file name", n)
+		}
+		if other, ok := slugs[sl]; ok {
+			return fmt.Errorf("series %#v and %#v have the same slug %#v", other, n, sl)
+		}
+		slugs[sl] = n
+		b.Series = append(b.Series, newSeries(b, n))
+	}
+
+	return nil
+}
+
+func (b *blog) renderSeries() error {
+	for _, s := range b.Series {
+		err := s.renderIndex()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *blog) findArchives() error {
+	b.Archive = newArchive(b, b.Entries)
+	return nil
+}
+
+func (b *blog) renderArchives() error {
+	return b.Archive.renderIndex()
+}
+
+func (b *blog) findTaxonomies() error {
+	b.Taxonomies = map[string]*taxonomy{}
+	for _, tc := range b.Config.Taxonomies {
+		tx, err := newTaxonomy(b, tc)
+		if err != nil {
+			return err
+		}
+		b.Taxonomies[tc.Name] = tx
+	}
+	return nil
+}
+
+func (b *blog) renderTaxonomies() error {
+	for _, tx := range b.Taxonomies {
+		err := tx.render()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *blog) readAuthors() error {
+	acs := b.Config.Authors
+	if b.Config.AuthorsFile != "" {
+		fromFile, err := readAuthorsFile(b.Config.AuthorsFile)
+		if err != nil {
+			return err
+		}
+		for id, ac := range acs {
+			fromFile[id] = ac
+		}
+		acs = fromFile
+	}
+
+	b.Authors = make([]*author, 0, len(acs))
+	for id, ac := range acs {
+		if id == "" || slugify(id) != id {
+			return fmt.Errorf("author id %#v may only contain lower case letters, digits and -", id)
+		}
+		a := newAuthor(b, id, ac)
+		a.configured = true
+		b.Authors = append(b.Authors, a)
+	}
+	sortAuthors(b.Authors)
+
+	return nil
+}
+
+func (b *blog) findAuthors() error {
+	for _, e := range b.Entries {
+		for _, a := range e.Authors {
+			a.Entries = append(a.Entries, e)
+		}
+	}
+
+	// authors that are only named by drafts are not published.
+	published := []*author{}
+	for _, a := range b.Authors {
+		if !a.configured && len(a.Entries) == 0 {
+			continue
+		}
+		sortByDate(a.Entries)
+		a.Modified = findLatestModified(a.Entries)
+		published = append(published, a)
+	}
+	b.Authors = published
+	sortAuthors(b.Authors)
+
+	return nil
+}
+
+func (b *blog) renderAuthors() error {
+	for _, a := range b.Authors {
+		err := a.renderIndex()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -359,6 +669,38 @@ func (b *blog) renderGroups() error {
 	return nil
 }
 
+func (b *blog) linkEntries() error {
+	linkNeighbours(b.Entries, func(e, prev, next *entry) {
+		e.Prev, e.Next = prev, next
+	})
+
+	for _, g := range b.Groups {
+		linkNeighbours(g.Entries, func(e, prev, next *entry) {
+			e.PrevInGroup, e.NextInGroup = prev, next
+		})
+	}
+
+	for _, e := range b.Entries {
+		e.PrevInTag = map[string]*entry{}
+		e.NextInTag = map[string]*entry{}
+	}
+	for _, t := range b.Tags {
+		linkNeighbours(t.Entries, func(e, prev, next *entry) {
+			e.PrevInTag[t.Name], e.NextInTag[t.Name] = prev, next
+		})
+	}
+
+	count := b.Config.RelatedCount
+	if count <= 0 {
+		count = defaultRelatedCount
+	}
+	for _, e := range b.Entries {
+		e.Related = e.findRelated(b.Entries, count)
+	}
+
+	return nil
+}
+
 func (b *blog) renderMainIndex() error {
 	var err error
 	var buf bytes.Buffer
@@ -369,7 +711,7 @@ func (b *blog) renderMainIndex() error {
 	}
 
 	fp := filepath.Join(b.OutputDirectory, "index.html")
-	err = os.WriteFile(fp, buf.Bytes(), 0644)
+	err = b.writeOutput(fp, buf.Bytes())
 	if err != nil {
 		return fmt.Errorf("failed to write main index file: %w", err)
 	}
@@ -378,30 +720,56 @@ func (b *blog) renderMainIndex() error {
 	return nil
 }
 
-func (b *blog) readEntries() error {
-	mds := []string{}
+// findMarkdownFiles walks the base directory once and sorts the md files of
+// the blog into entries and pages: the md files directly in the base
+// directory and nested static pages, cf. isPage.
+func (b *blog) findMarkdownFiles() ([]string, []string, error) {
+	entries, pages := []string{}, []string{}
 	walker := func(pth string, info os.FileInfo, err error) error {
-		if filepath.Dir(pth) == b.BaseDirectory { // skip base dir
-			log.Printf("skipping in base dir: %#v", pth)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && b.isForeignDirectory(pth) {
+			return filepath.SkipDir
+		}
+		if filepath.Ext(info.Name()) != ".md" || b.ownerOf(pth) != b {
+			return nil
+		}
+		if filepath.Dir(pth) == b.BaseDirectory {
+			pages = append(pages, pth)
+			log.Printf("found top: %#v", pth)
 			return nil
 		}
-		if filepath.Ext(info.Name()) == ".md" {
-			mds = append(mds, pth)
+		isPage, err := b.isPage(pth)
+		if err != nil {
+			return err
 		}
-		return err
+		if isPage {
+			pages = append(pages, pth)
+			log.Printf("found page: %#v", pth)
+		} else {
+			entries = append(entries, pth)
+		}
+		return nil
 	}
 	err := filepath.Walk(b.BaseDirectory, walker)
 	if err != nil {
-		return fmt.Errorf("failed to search for md files: %w", err)
+		return nil, nil, fmt.Errorf("failed to search for md files: %w", err)
 	}
-	verbose("walked base-dir %#v and found %v md files.", b.BaseDirectory, len(mds))
+	verbose("walked base-dir %#v and found %v entries and %v pages.", b.BaseDirectory, len(entries), len(pages))
+
+	return entries, pages, nil
+}
 
+func (b *blog) readEntries(mds []string) error {
 	b.Entries = make([]*entry, 0, len(mds))
+	errs := []error{}
 	for _, md := range mds {
 
 		e, err := newEntry(b, md)
 		if err != nil {
-			return err
+			errs = append(errs, err)
+			continue
 		}
 
 		isDraft := strings.Contains(filepath.ToSlash(md), "/draft/")
@@ -412,9 +780,37 @@ func (b *blog) readEntries() error {
 		}
 	}
 
+	err := b.validateFrontMatter()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to read entries:\n%w", errors.Join(errs...))
+	}
+
 	sortByDate(b.Entries)
 	return nil
 }
+
+func (b *blog) validateFrontMatter() error {
+	schema := b.Config.FrontMatterSchema
+	if schema == nil {
+		return nil
+	}
+
+	violations := []error{}
+	for _, e := range append(b.Entries, b.DraftEntries...) {
+		for _, v := range schema.validate(e.effectiveParams()) {
+			violations = append(violations, fmt.Errorf("%s: %s", e.MDFile, v))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("front matter violates schema:\n%w", errors.Join(violations...))
+	}
+	return nil
+}
+
 func (b *blog) writeEntries() error {
 	for _, e := range b.Entries {
 		err := e.writeHTML()
@@ -437,22 +833,7 @@ func (b *blog) writeDraftEntries() error {
 	return nil
 }
 
-func (b *blog) readTops() error {
-	fs, err := os.ReadDir(b.BaseDirectory)
-	if err != nil {
-		return err
-	}
-
-	mds := []string{}
-	for _, fi := range fs {
-		if filepath.Ext(fi.Name()) == ".md" {
-			pth := filepath.Join(b.BaseDirectory, fi.Name())
-			mds = append(mds, pth)
-			log.Printf("found top: %#v", pth)
-		}
-	}
-	verbose("scanned base-dir %#v and found %v md files.", b.BaseDirectory, len(mds))
-
+func (b *blog) readTops(mds []string) error {
 	b.Tops = make([]*top, 0, len(mds))
 	for _, md := range mds {
 		t, err := newTop(b, md)
@@ -461,6 +842,7 @@ func (b *blog) readTops() error {
 		}
 		b.Tops = append(b.Tops, t)
 	}
+	sortTops(b.Tops)
 	return nil
 }
 
@@ -2,11 +2,17 @@ package main
 
 import (
 	"fmt"
+	"html"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
 func measure(f func() error, eh func(error), mf string, args ...interface{}) {
 	start := time.Now()
 	err := f()
@@ -73,8 +79,74 @@ func inferHTMLFilePath(b *blog, md string) (string, error) {
 	}
 
 	bs := filepath.Base(rel)
-	fn := fmt.Sprintf("%s.html", bs[:len(bs)-len(".md")])
+	name := bs[:len(bs)-len(".md")]
+	if code := b.languageSuffix(md); code != "" {
+		name = strings.TrimSuffix(name, "."+code)
+	}
+	fn := fmt.Sprintf("%s.html", name)
 	out := filepath.Join(b.OutputDirectory, filepath.Dir(rel), fn)
 
 	return out, nil
 }
+
+func plainText(h string) string {
+	stripped := htmlTag.ReplaceAllString(h, "")
+	return strings.Join(strings.Fields(html.UnescapeString(stripped)), " ")
+}
+
+func truncateText(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	cut := string([]rune(s)[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
+
+func slugify(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return sb.String()
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDate interprets dates without explicit offset in location loc.
+func parseDate(raw interface{}, loc *time.Location) (time.Time, error) {
+	switch v := raw.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, l := range dateLayouts {
+			t, err := time.ParseInLocation(l, v, loc)
+			if err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unsupported date format %#v", v)
+	default:
+		return time.Time{}, fmt.Errorf("date is not a string but %T", raw)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// readData loads the JSON, YAML and CSV files in dir keyed by their name
+// without extension, sub directories become nested maps. CSV files are read as
+// a list of records keyed by the header row.
+func readData(dir string) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	fs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory %#v: %w", dir, err)
+	}
+
+	for _, fi := range fs {
+		fp := filepath.Join(dir, fi.Name())
+		ext := filepath.Ext(fi.Name())
+		key := strings.TrimSuffix(fi.Name(), ext)
+
+		if fi.IsDir() {
+			result[key], err = readData(fp)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var v interface{}
+		switch ext {
+		case ".json":
+			v, err = readJSONData(fp)
+		case ".yaml", ".yml":
+			v, err = readYAMLData(fp)
+		case ".csv":
+			v, err = readCSVData(fp)
+		default:
+			log.Printf("skipping data file with unsupported extension: %#v", fp)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data file %#v: %w", fp, err)
+		}
+		result[key] = v
+	}
+
+	return result, nil
+}
+
+func readJSONData(fp string) (interface{}, error) {
+	bt, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(bt, &result)
+	return result, err
+}
+
+func readYAMLData(fp string) (interface{}, error) {
+	bt, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = yaml.Unmarshal(bt, &result)
+	return normalizeValue(result), err
+}
+
+func readCSVData(fp string) (interface{}, error) {
+	fh, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	rows, err := csv.NewReader(fh).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]string{}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	header := rows[0]
+	for _, row := range rows[1:] {
+		rec := map[string]string{}
+		for i, col := range header {
+			if i < len(row) {
+				rec[col] = row[i]
+			}
+		}
+		result = append(result, rec)
+	}
+
+	return result, nil
+}
+
+func (b *blog) readData() error {
+	if b.Config.DataDirectory == "" {
+		return nil
+	}
+
+	var err error
+	b.Data, err = readData(b.Config.DataDirectory)
+	if err != nil {
+		return err
+	}
+	verbose("read %v data files from %#v.", len(b.Data), b.Config.DataDirectory)
+
+	return nil
+}
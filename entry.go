@@ -7,7 +7,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/yuin/goldmark"
@@ -20,6 +22,15 @@ import (
 	"github.com/fgeller/relabs"
 )
 
+const (
+	defaultWordsPerMinute = 200
+	defaultExcerptLength  = 200
+	defaultRelatedCount   = 5
+)
+
+// moreSeparator marks the end of an entry's summary in its markdown source.
+var moreSeparator = regexp.MustCompile(`<!--\s*more\s*-->`)
+
 type entry struct {
 	MDFile   string
 	HTMLFile string
@@ -28,16 +39,45 @@ type entry struct {
 	Summary  template.HTML
 	Posted   time.Time
 	Modified time.Time
-	Author   string
+	Author   *author
+	Authors  []*author
 	Tags     []string
+	Params   params
+	Weight   int
+
+	Language     string
+	Translations []*entry
+
+	Taxonomies map[string][]string
+
+	SeriesName     string
+	SeriesPart     int
+	Series         *series
+	SeriesPosition int
+	SeriesTotal    int
 
 	RenderedHTML template.HTML
+	Resources    []*resource
+	Gallery      *gallery
+
+	WordCount   int
+	ReadingTime int // in minutes
+	Excerpt     string
+
+	// Prev links to the older and Next to the newer entry.
+	Prev        *entry
+	Next        *entry
+	PrevInGroup *entry
+	NextInGroup *entry
+	PrevInTag   map[string]*entry
+	NextInTag   map[string]*entry
+	Related     []*entry
 
 	Blog *blog
 }
 
 func newEntry(b *blog, md string) (*entry, error) {
-	e := &entry{MDFile: md, Blog: b}
+	e := &entry{MDFile: md, Blog: b, Language: b.Language}
 
 	html, err := inferHTMLFilePath(b, md)
 	if err != nil {
@@ -50,9 +90,38 @@ func newEntry(b *blog, md string) (*entry, error) {
 		return nil, err
 	}
 
+	err = e.readResources()
+	if err != nil {
+		return nil, err
+	}
+
 	return e, e.readMD()
 }
 
+// effectiveParams returns the front matter as mugo interprets it: author and
+// authors are set to the resolved authors, which includes the default-author
+// for entries that don't name one.
+func (e *entry) effectiveParams() params {
+	result := params{}
+	for k, v := range e.Params {
+		result[k] = v
+	}
+	if len(e.Authors) == 0 {
+		return result
+	}
+	if !result.Has("author") {
+		result["author"] = e.Author.ID
+	}
+	if !result.Has("authors") {
+		ids := make([]interface{}, 0, len(e.Authors))
+		for _, a := range e.Authors {
+			ids = append(ids, a.ID)
+		}
+		result["authors"] = ids
+	}
+	return result
+}
+
 func (e *entry) readModified() error {
 	mf, err := os.Open(e.MDFile)
 	if err != nil {
@@ -65,6 +134,9 @@ func (e *entry) readModified() error {
 	}
 
 	e.Modified = st.ModTime()
+	if gt, ok := e.Blog.gitModTime(e.MDFile); ok {
+		e.Modified = gt
+	}
 
 	return nil
 }
@@ -74,31 +146,110 @@ func (e *entry) parseHeader(ctx parser.Context) error {
 	var err error
 	var ok bool
 
+	e.Params = newParams(header)
+	e.Weight = e.Params.Int("weight")
+
 	e.Title, ok = header["title"].(string)
 	if !ok {
 		return fmt.Errorf("title is missing in %#v", e.MDFile)
 	}
 
-	e.Author, ok = header["author"].(string)
-	if !ok {
-		return fmt.Errorf("author is missing in %#v", e.MDFile)
+	e.Authors = []*author{}
+	addAuthor := func(name string) {
+		a := e.Blog.findAuthor(name)
+		for _, ea := range e.Authors {
+			if ea == a {
+				return // listed under both author and authors
+			}
+		}
+		e.Authors = append(e.Authors, a)
+	}
+	for _, key := range []string{"author", "authors"} {
+		switch raw := header[key].(type) {
+		case nil:
+		case string:
+			addAuthor(raw)
+		case []interface{}:
+			for _, v := range raw {
+				an, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("%s are not passed as array of strings in %#v", key, e.MDFile)
+				}
+				addAuthor(an)
+			}
+		default:
+			return fmt.Errorf("%s is not passed as string or array of strings in %#v", key, e.MDFile)
+		}
+	}
+	if len(e.Authors) == 0 && e.Blog.Config.DefaultAuthor != "" {
+		e.Authors = append(e.Authors, e.Blog.findAuthor(e.Blog.Config.DefaultAuthor))
+	}
+	if len(e.Authors) > 0 {
+		e.Author = e.Authors[0]
 	}
 
-	e.Posted, err = time.Parse("2006-01-02 15:04", header["date"].(string))
+	rawDate, ok := header["date"]
+	if !ok {
+		return fmt.Errorf("date is missing in %#v", e.MDFile)
+	}
+	e.Posted, err = parseDate(rawDate, e.Blog.Config.Location())
 	if err != nil {
-		e.Posted, err = time.Parse("2006-01-02", header["date"].(string))
+		return fmt.Errorf("failed to parse header date in %#v: %w", e.MDFile, err)
+	}
+
+	if rawUpdated, exists := header["updated"]; exists {
+		e.Modified, err = parseDate(rawUpdated, e.Blog.Config.Location())
 		if err != nil {
-			return fmt.Errorf("failed to parse header date in %#v: %w", e.MDFile, err)
+			return fmt.Errorf("failed to parse header updated in %#v: %w", e.MDFile, err)
 		}
 	}
 
 	e.Tags = []string{}
-	raw, ok := header["tags"].([]interface{})
-	if !ok {
-		return fmt.Errorf("tags are not passed as array of strings in %#v: %w", e.MDFile, err)
+	if rawTags, exists := header["tags"]; exists && rawTags != nil {
+		raw, ok := rawTags.([]interface{})
+		if !ok {
+			return fmt.Errorf("tags are not passed as array of strings in %#v", e.MDFile)
+		}
+		for _, v := range raw {
+			t, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("tags are not passed as array of strings in %#v", e.MDFile)
+			}
+			e.Tags = append(e.Tags, e.Blog.resolveTagAlias(t))
+		}
+	}
+
+	e.Taxonomies = map[string][]string{}
+	for _, tc := range e.Blog.Config.Taxonomies {
+		switch raw := header[tc.Name].(type) {
+		case nil:
+		case string:
+			e.Taxonomies[tc.Name] = []string{raw}
+		case []interface{}:
+			for _, v := range raw {
+				tn, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("%s are not passed as array of strings in %#v", tc.Name, e.MDFile)
+				}
+				e.Taxonomies[tc.Name] = append(e.Taxonomies[tc.Name], tn)
+			}
+		default:
+			return fmt.Errorf("%s are not passed as string or array of strings in %#v", tc.Name, e.MDFile)
+		}
+	}
+
+	if raw, exists := header["series"]; exists {
+		e.SeriesName, ok = raw.(string)
+		if !ok {
+			return fmt.Errorf("series is not a string in %#v", e.MDFile)
+		}
 	}
-	for _, t := range raw {
-		e.Tags = append(e.Tags, t.(string))
+
+	if raw, exists := header["series-part"]; exists {
+		e.SeriesPart, ok = raw.(int)
+		if !ok {
+			return fmt.Errorf("series-part is not an integer in %#v", e.MDFile)
+		}
 	}
 
 	_, ok = header["summary"].(string)
@@ -127,7 +278,7 @@ func (e *entry) URL() string {
 }
 
 func (e *entry) RelativeURL() string {
-	return urlJoin("/", e.Group(), e.Dir(), e.HTMLFileName())
+	return urlJoin(e.Blog.rootPath(), e.Group(), e.Dir(), e.HTMLFileName())
 }
 
 func (e *entry) BaseURL() (*url.URL, error) {
@@ -163,15 +314,44 @@ func (e *entry) readMD() error {
 		return err
 	}
 
-	err = md.Convert(src, &buf, parser.WithContext(ctx))
+	// parse the header first so that shortcodes have access to the entry.
+	doc := md.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+	err = e.parseHeader(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	err = e.readGallery()
+	if err != nil {
+		return err
+	}
+
+	src, shortcodes, err := e.expandShortcodes(md, src, doc)
+	if err != nil {
+		return err
+	}
+
+	err = md.Convert(src, &buf)
 	if err != nil {
 		return fmt.Errorf("failed to convert markdown to html: %w", err)
 	}
-	e.RenderedHTML = template.HTML(buf.String())
+	e.RenderedHTML = template.HTML(shortcodes.Replace(buf.String()))
 
-	err = e.parseHeader(ctx)
+	e.RenderedHTML, err = e.Blog.responsiveImages(e.RenderedHTML, e.MDFile)
 	if err != nil {
-		return fmt.Errorf("failed to parse header: %w", err)
+		return err
+	}
+
+	more := moreSeparator.FindIndex(src)
+	if e.Summary == "" {
+		if more != nil {
+			var p bytes.Buffer
+			err := md.Convert(src[:more[0]], &p)
+			if err != nil {
+				return fmt.Errorf("failed to convert summary to html: %w", err)
+			}
+			e.Summary = template.HTML(p.String())
+		}
 	}
 
 	if e.Summary == "" {
@@ -185,9 +365,37 @@ func (e *entry) readMD() error {
 		}
 	}
 
+	e.Summary = template.HTML(shortcodes.Replace(string(e.Summary)))
+	e.Summary, err = e.Blog.responsiveImages(e.Summary, e.MDFile)
+	if err != nil {
+		return err
+	}
+
+	e.countWords(more != nil)
+
 	return nil
 }
 
+func (e *entry) countWords(more bool) {
+	plain := plainText(string(e.RenderedHTML))
+	e.WordCount = len(strings.Fields(plain))
+
+	wpm := e.Blog.Config.WordsPerMinute
+	if wpm <= 0 {
+		wpm = defaultWordsPerMinute
+	}
+	e.ReadingTime = (e.WordCount + wpm - 1) / wpm
+
+	el := e.Blog.Config.ExcerptLength
+	if el <= 0 {
+		el = defaultExcerptLength
+	}
+	if more {
+		plain = plainText(string(e.Summary))
+	}
+	e.Excerpt = truncateText(plain, el)
+}
+
 func (e *entry) writeHTML() error {
 	var err error
 	var buf bytes.Buffer
@@ -197,7 +405,12 @@ func (e *entry) writeHTML() error {
 		return fmt.Errorf("failed to execute entry template: %w", err)
 	}
 
-	err = os.WriteFile(e.HTMLFile, buf.Bytes(), 0644)
+	err = os.MkdirAll(filepath.Dir(e.HTMLFile), 0770)
+	if err != nil {
+		return err
+	}
+
+	err = e.Blog.writeOutput(e.HTMLFile, buf.Bytes())
 	if err != nil {
 		return err
 	}
@@ -218,3 +431,54 @@ func sortByDate(entries []*entry) {
 	}
 	sort.Slice(entries, chrono)
 }
+
+// linkNeighbours expects entries to be sorted by date, newest first.
+func linkNeighbours(entries []*entry, link func(e, prev, next *entry)) {
+	for i, e := range entries {
+		var prev, next *entry
+		if i > 0 {
+			next = entries[i-1]
+		}
+		if i < len(entries)-1 {
+			prev = entries[i+1]
+		}
+		link(e, prev, next)
+	}
+}
+
+func (e *entry) sharedTags(o *entry) int {
+	count := 0
+	for _, a := range e.Tags {
+		for _, b := range o.Tags {
+			if a == b {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+func (e *entry) findRelated(candidates []*entry, count int) []*entry {
+	scores := map[*entry]int{}
+	result := []*entry{}
+	for _, c := range candidates {
+		if c == e {
+			continue
+		}
+		if s := e.sharedTags(c); s > 0 {
+			scores[c] = s
+			result = append(result, c)
+		}
+	}
+
+	byScore := func(i, j int) bool {
+		return scores[result[i]] > scores[result[j]]
+	}
+	sort.SliceStable(result, byScore)
+
+	if len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/feeds"
+)
+
+const feedEntryCount = 3
+
+func newFeed(fc *feedConfig, title, link string, entries []*entry) *feeds.Feed {
+	fd := &feeds.Feed{
+		Title:       title,
+		Link:        &feeds.Link{Href: link},
+		Description: fc.Description,
+		Author:      &feeds.Author{Name: fc.AuthorName, Email: fc.AuthorEmail},
+	}
+	// the newest entry dates the feed so that it only changes with its
+	// entries, a feed without entries keeps the zero time.
+	if len(entries) > 0 {
+		fd.Created = entries[0].Posted
+	}
+
+	for _, e := range entries {
+		itm := &feeds.Item{
+			Title:   e.Title,
+			Link:    &feeds.Link{Href: e.URL()},
+			Source:  &feeds.Link{Href: e.URL()},
+			Created: e.Posted,
+			Content: string(e.RenderedHTML),
+		}
+		if e.Author != nil {
+			itm.Author = &feeds.Author{Name: e.Author.Name, Email: e.Author.Email}
+		}
+		fd.Add(itm)
+	}
+
+	return fd
+}
+
+func latest(entries []*entry, count int) []*entry {
+	if len(entries) < count {
+		count = len(entries)
+	}
+	return entries[:count]
+}
+
+// writeFeeds writes the feed as name.rss.xml and name.atom.xml into dir, as
+// enabled in the feed config.
+func (b *blog) writeFeeds(fc *feedConfig, fd *feeds.Feed, dir, name string) error {
+	err := os.MkdirAll(dir, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create feed directory [%s] err=%w", dir, err)
+	}
+
+	if fc.RSSEnabled {
+		of := filepath.Join(dir, name+".rss.xml")
+		err := b.writeFeedFile(of, fd.WriteRss)
+		if err != nil {
+			return fmt.Errorf("failed to write feed to rss: %w", err)
+		}
+	}
+
+	if fc.AtomEnabled {
+		of := filepath.Join(dir, name+".atom.xml")
+		err := b.writeFeedFile(of, fd.WriteAtom)
+		if err != nil {
+			return fmt.Errorf("failed to write feed to atom: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (b *blog) writeFeedFile(of string, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	err := write(&buf)
+	if err != nil {
+		return err
+	}
+
+	err = b.writeOutput(of, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write feed file %#v: %w", of, err)
+	}
+	return nil
+}
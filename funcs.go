@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"math/rand"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// libraryFuncs are the general purpose template functions. Collection
+// functions access fields, methods and map keys of items by name, nested
+// keys are separated by dots like "Params.cover". Like in Hugo, slice
+// replaces the builtin of the same name to construct rather than slice a list.
+func (b *blog) libraryFuncs() template.FuncMap {
+	return template.FuncMap{
+		"slugify":   slugify,
+		"truncate":  func(n int, s string) string { return truncateText(s, n) },
+		"plainify":  func(s interface{}) string { return plainText(fmt.Sprint(s)) },
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"trim":      strings.TrimSpace,
+		"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":  func(sub, s string) bool { return strings.Contains(s, sub) },
+		"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":     func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":      func(sep string, l []string) string { return strings.Join(l, sep) },
+
+		"first":   first,
+		"last":    last,
+		"where":   where,
+		"sortBy":  sortBy,
+		"groupBy": groupBy,
+		"shuffle": shuffle,
+
+		"absURL": b.absURL,
+		"relURL": b.relURL,
+
+		"markdownify":  markdownify,
+		"jsonify":      jsonify,
+		"safeHTML":     func(s string) template.HTML { return template.HTML(s) },
+		"safeHTMLAttr": func(s string) template.HTMLAttr { return template.HTMLAttr(s) },
+		"safeURL":      func(s string) template.URL { return template.URL(s) },
+		"safeCSS":      func(s string) template.CSS { return template.CSS(s) },
+		"safeJS":       func(s string) template.JS { return template.JS(s) },
+
+		"add": func(a, b interface{}) (interface{}, error) { return arith(a, b, '+') },
+		"sub": func(a, b interface{}) (interface{}, error) { return arith(a, b, '-') },
+		"mul": func(a, b interface{}) (interface{}, error) { return arith(a, b, '*') },
+		"div": func(a, b interface{}) (interface{}, error) { return arith(a, b, '/') },
+		"mod": func(a, b interface{}) (interface{}, error) { return arith(a, b, '%') },
+		"min": func(a, b interface{}) (interface{}, error) { return arith(a, b, '<') },
+		"max": func(a, b interface{}) (interface{}, error) { return arith(a, b, '>') },
+
+		"dict":  dict,
+		"slice": func(items ...interface{}) []interface{} { return items },
+	}
+}
+
+func (b *blog) absURL(s string) string {
+	u, err := url.Parse(s)
+	if err == nil && u.IsAbs() {
+		return s
+	}
+	return urlJoin(b.BaseURL, strings.TrimPrefix(s, "/"))
+}
+
+func (b *blog) relURL(s string) string {
+	u, err := url.Parse(s)
+	if err == nil && u.IsAbs() {
+		return s
+	}
+	return urlJoin(b.rootPath(), strings.TrimPrefix(s, "/"))
+}
+
+func markdownify(s string) (template.HTML, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(
+			html.WithXHTML(),
+			html.WithUnsafe(),
+		),
+	)
+
+	var buf bytes.Buffer
+	err := md.Convert([]byte(s), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(trimParagraph(buf.String())), nil
+}
+
+// trimParagraph unwraps html that is a single paragraph, as inline markdown
+// should not be wrapped in one.
+func trimParagraph(h string) string {
+	out := strings.TrimSpace(h)
+	if strings.Count(out, "<p>") == 1 && strings.HasPrefix(out, "<p>") && strings.HasSuffix(out, "</p>") {
+		out = out[len("<p>") : len(out)-len("</p>")]
+	}
+	return out
+}
+
+func jsonify(v interface{}) (template.HTML, error) {
+	bt, err := json.Marshal(v)
+	return template.HTML(bt), err
+}
+
+func dict(kvs ...interface{}) (map[string]interface{}, error) {
+	if len(kvs)%2 != 0 {
+		return nil, fmt.Errorf("dict expects an even number of arguments")
+	}
+
+	result := map[string]interface{}{}
+	for i := 0; i < len(kvs); i += 2 {
+		k, ok := kvs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", kvs[i])
+		}
+		result[k] = kvs[i+1]
+	}
+	return result, nil
+}
+
+func listValue(coll interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(coll)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		return v, nil
+	default:
+		return v, fmt.Errorf("expected a list but got %T", coll)
+	}
+}
+
+func items(v reflect.Value) []interface{} {
+	result := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		result = append(result, v.Index(i).Interface())
+	}
+	return result
+}
+
+func first(n int, coll interface{}) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("first expects a non-negative count, got %d", n)
+	}
+	v, err := listValue(coll)
+	if err != nil {
+		return nil, err
+	}
+	if n > v.Len() {
+		n = v.Len()
+	}
+	return v.Slice(0, n).Interface(), nil
+}
+
+func last(n int, coll interface{}) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("last expects a non-negative count, got %d", n)
+	}
+	v, err := listValue(coll)
+	if err != nil {
+		return nil, err
+	}
+	if n > v.Len() {
+		n = v.Len()
+	}
+	return v.Slice(v.Len()-n, v.Len()).Interface(), nil
+}
+
+// lookup resolves a dotted key against maps, methods without arguments and
+// struct fields.
+func lookup(item interface{}, key string) (interface{}, bool) {
+	cur := reflect.ValueOf(item)
+	for _, part := range strings.Split(key, ".") {
+		if !cur.IsValid() {
+			return nil, false
+		}
+
+		if m := cur.MethodByName(part); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() > 0 {
+			cur = m.Call(nil)[0]
+			continue
+		}
+
+		for cur.Kind() == reflect.Pointer || cur.Kind() == reflect.Interface {
+			if cur.IsNil() {
+				return nil, false
+			}
+			cur = cur.Elem()
+		}
+
+		switch cur.Kind() {
+		case reflect.Map:
+			cur = cur.MapIndex(reflect.ValueOf(part))
+		case reflect.Struct:
+			cur = cur.FieldByName(part)
+		default:
+			return nil, false
+		}
+	}
+
+	if !cur.IsValid() || !cur.CanInterface() {
+		return nil, false
+	}
+	return cur.Interface(), true
+}
+
+func number(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// compare returns -1, 0 or 1 for numbers, strings and times, and false for
+// values that cannot be ordered.
+func compare(a, b interface{}) (int, bool) {
+	if an, ok := number(a); ok {
+		bn, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case an < bn:
+			return -1, true
+		case an > bn:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+
+	return 0, false
+}
+
+func matches(v interface{}, op string, arg interface{}) (bool, error) {
+	if op == "in" {
+		l, err := listValue(arg)
+		if err != nil {
+			return false, err
+		}
+		for _, a := range items(l) {
+			if c, ok := compare(v, a); (ok && c == 0) || reflect.DeepEqual(v, a) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	c, ok := compare(v, arg)
+	if !ok {
+		eq := reflect.DeepEqual(v, arg) || fmt.Sprint(v) == fmt.Sprint(arg)
+		switch op {
+		case "=", "==", "eq":
+			return eq, nil
+		case "!=", "ne":
+			return !eq, nil
+		}
+		return false, nil
+	}
+
+	switch op {
+	case "=", "==", "eq":
+		return c == 0, nil
+	case "!=", "ne":
+		return c != 0, nil
+	case "<", "lt":
+		return c < 0, nil
+	case "<=", "le":
+		return c <= 0, nil
+	case ">", "gt":
+		return c > 0, nil
+	case ">=", "ge":
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unsupported operator %#v", op)
+}
+
+// where filters coll by the item's key: where coll key value, or with an
+// operator: where coll key op value.
+func where(coll interface{}, key string, args ...interface{}) ([]interface{}, error) {
+	var op string
+	var arg interface{}
+	switch len(args) {
+	case 1:
+		op, arg = "eq", args[0]
+	case 2:
+		o, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("where expects an operator string but got %T", args[0])
+		}
+		op, arg = o, args[1]
+	default:
+		return nil, fmt.Errorf("where expects a value or an operator and a value")
+	}
+
+	l, err := listValue(coll)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []interface{}{}
+	for _, it := range items(l) {
+		v, _ := lookup(it, key)
+		ok, err := matches(v, op, arg)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, it)
+		}
+	}
+	return result, nil
+}
+
+// sortBy sorts by the item's key ascending, or descending if order is "desc".
+func sortBy(coll interface{}, key string, order ...string) ([]interface{}, error) {
+	l, err := listValue(coll)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := len(order) > 0 && order[0] == "desc"
+	result := items(l)
+	less := func(i, j int) bool {
+		a, _ := lookup(result[i], key)
+		b, _ := lookup(result[j], key)
+		c, ok := compare(a, b)
+		if !ok {
+			c = strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.SliceStable(result, less)
+
+	return result, nil
+}
+
+type itemGroup struct {
+	Key   interface{}
+	Items []interface{}
+}
+
+// groupBy groups items by their key, in order of first occurrence.
+func groupBy(coll interface{}, key string) ([]*itemGroup, error) {
+	l, err := listValue(coll)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*itemGroup{}
+	byKey := map[string]*itemGroup{}
+	for _, it := range items(l) {
+		v, _ := lookup(it, key)
+		k := fmt.Sprint(v)
+		g, ok := byKey[k]
+		if !ok {
+			g = &itemGroup{Key: v}
+			byKey[k] = g
+			result = append(result, g)
+		}
+		g.Items = append(g.Items, it)
+	}
+	return result, nil
+}
+
+// shuffle is deterministic for a given seed so that builds are reproducible.
+func shuffle(seed int, coll interface{}) ([]interface{}, error) {
+	l, err := listValue(coll)
+	if err != nil {
+		return nil, err
+	}
+
+	result := items(l)
+	rnd := rand.New(rand.NewSource(int64(seed)))
+	rnd.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result, nil
+}
+
+// arith uses integer arithmetic if both arguments are integers.
+func arith(a, b interface{}, op rune) (interface{}, error) {
+	an, aok := number(a)
+	bn, bok := number(b)
+	if !aok || !bok {
+		return nil, fmt.Errorf("expected numbers but got %T and %T", a, b)
+	}
+	if (op == '/' || op == '%') && bn == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+
+	if isInt(a) && isInt(b) {
+		ai, bi := int(an), int(bn)
+		switch op {
+		case '+':
+			return ai + bi, nil
+		case '-':
+			return ai - bi, nil
+		case '*':
+			return ai * bi, nil
+		case '/':
+			return ai / bi, nil
+		case '%':
+			return ai % bi, nil
+		}
+	}
+
+	switch op {
+	case '+':
+		return an + bn, nil
+	case '-':
+		return an - bn, nil
+	case '*':
+		return an * bn, nil
+	case '/':
+		return an / bn, nil
+	case '%':
+		return math.Mod(an, bn), nil
+	case '<':
+		if bn < an {
+			return b, nil
+		}
+		return a, nil
+	case '>':
+		if bn > an {
+			return b, nil
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+func isInt(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
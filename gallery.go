@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultThumbnailWidth = 320
+	captionsFile          = "captions.yaml"
+)
+
+// gallery collects the images of a directory next to an entry, configured via
+// front matter "gallery: <dir>" relative to the entry's directory. The photos
+// are ordered as listed in the optional captions.yaml sidecar file, remaining
+// photos follow by name. The sidecar file is not synced to the output.
+type gallery struct {
+	Dir    string
+	Photos []*photo
+
+	Entry *entry
+}
+
+type photo struct {
+	Name    string // file name in the gallery directory
+	Path    string
+	Caption string
+
+	Taken       time.Time
+	Camera      string
+	Lens        string
+	Exposure    string // e.g. "1/250s"
+	Aperture    string // e.g. "f/2.8"
+	ISO         int
+	FocalLength string // e.g. "35mm"
+
+	Width     int
+	Height    int
+	Thumbnail *imageVariant
+
+	// file is the name of the published copy without EXIF data, which
+	// differs from Name when writing in place.
+	file string
+
+	// Image is set when the image pipeline is enabled.
+	Image *responsiveImage
+
+	Gallery *gallery
+}
+
+type captionConfig struct {
+	File    string `yaml:"file"`
+	Caption string `yaml:"caption"`
+}
+
+func (p *photo) URL() string {
+	e := p.Gallery.Entry
+	return urlJoin(e.Blog.BaseURL, e.Group(), e.Dir(), p.Gallery.Dir, p.file)
+}
+
+func (p *photo) RelativeURL() string {
+	e := p.Gallery.Entry
+	return urlJoin(e.Blog.rootPath(), e.Group(), e.Dir(), p.Gallery.Dir, p.file)
+}
+
+func (p *photo) ThumbnailURL() string {
+	e := p.Gallery.Entry
+	return urlJoin(e.Blog.BaseURL, e.Group(), e.Dir(), p.Gallery.Dir, p.Thumbnail.Name)
+}
+
+func (p *photo) ThumbnailRelativeURL() string {
+	e := p.Gallery.Entry
+	return urlJoin(e.Blog.rootPath(), e.Group(), e.Dir(), p.Gallery.Dir, p.Thumbnail.Name)
+}
+
+func (e *entry) readGallery() error {
+	dir := e.Params.String("gallery")
+	if dir == "" {
+		return nil
+	}
+
+	dir = filepath.Clean(filepath.FromSlash(dir))
+	if filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("gallery %#v of %#v must be a directory below the entry's directory", dir, e.MDFile)
+	}
+
+	g := &gallery{Dir: filepath.ToSlash(dir), Photos: []*photo{}, Entry: e}
+	src := filepath.Join(filepath.Dir(e.MDFile), dir)
+
+	fs, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read gallery of %#v: %w", e.MDFile, err)
+	}
+
+	captions, err := readCaptions(filepath.Join(src, captionsFile))
+	if err != nil {
+		return fmt.Errorf("failed to read captions of %#v: %w", e.MDFile, err)
+	}
+	order := map[string]int{}
+	for i, c := range captions {
+		order[c.File] = i
+	}
+
+	for _, fi := range fs {
+		if fi.IsDir() || !isProcessableImage(fi.Name()) || isGenerated(fi.Name()) {
+			continue
+		}
+		p, err := e.Blog.readPhoto(g, filepath.Join(src, fi.Name()))
+		if err != nil {
+			return err
+		}
+		if i, ok := order[p.Name]; ok {
+			p.Caption = captions[i].Caption
+		}
+		g.Photos = append(g.Photos, p)
+	}
+
+	byOrder := func(i, j int) bool {
+		a, b := g.Photos[i], g.Photos[j]
+		ai, aok := order[a.Name]
+		bi, bok := order[b.Name]
+		switch {
+		case aok && bok:
+			return ai < bi
+		case aok != bok:
+			return aok
+		default:
+			return a.Name < b.Name
+		}
+	}
+	sort.Slice(g.Photos, byOrder)
+
+	for _, c := range captions {
+		if _, err := os.Stat(filepath.Join(src, c.File)); err != nil {
+			return fmt.Errorf("captions of gallery %#v refer to missing file %#v", src, c.File)
+		}
+	}
+
+	e.Gallery = g
+	verbose("read gallery %#v with %v photos.", src, len(g.Photos))
+	return nil
+}
+
+func readCaptions(fn string) ([]*captionConfig, error) {
+	byt, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*captionConfig
+	err = yaml.Unmarshal(byt, &result)
+	return result, err
+}
+
+func (b *blog) readPhoto(g *gallery, src string) (*photo, error) {
+	p := &photo{Name: filepath.Base(src), Path: src, Gallery: g}
+
+	si, err := readSourceImage(src)
+	if err != nil {
+		return nil, err
+	}
+	p.Width, p.Height = si.width, si.height
+	p.readEXIF(si.byt, b.Config.Location())
+
+	dst, err := b.outputPath(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := b.imagesConfig().ThumbnailWidth
+	if tw <= 0 {
+		tw = defaultThumbnailWidth
+	}
+	if tw > si.width {
+		tw = si.width
+	}
+	p.Thumbnail, err = b.variant(si, dst, tw)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Config.Images == nil {
+		// still refer to the full size variant, which is the original
+		// without EXIF data, e.g. GPS, in case of writing in place.
+		full, err := b.variant(si, dst, si.width)
+		if err != nil {
+			return nil, err
+		}
+		p.file = full.Name
+		return p, nil
+	}
+
+	pi, err := b.processImage(src, dst)
+	if err != nil {
+		return nil, err
+	}
+	p.file = pi.full().Name
+	sizes := b.Config.Images.Sizes
+	if sizes == "" {
+		sizes = defaultImageSizes
+	}
+	p.Image = newResponsiveImage(p.URL(), sizes, pi)
+
+	return p, nil
+}
+
+// readEXIF fills in the photo's metadata, the output files don't carry EXIF
+// data anymore. Missing fields are left empty.
+func (p *photo) readEXIF(byt []byte, loc *time.Location) {
+	x, err := exif.Decode(bytes.NewReader(byt))
+	if err != nil {
+		return
+	}
+
+	str := func(name exif.FieldName) string {
+		tag, err := x.Get(name)
+		if err != nil {
+			return ""
+		}
+		s, err := tag.StringVal()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.Trim(s, "\x00"))
+	}
+	rat := func(name exif.FieldName) *big.Rat {
+		tag, err := x.Get(name)
+		if err != nil {
+			return nil
+		}
+		r, err := tag.Rat(0)
+		if err != nil || r.Sign() <= 0 {
+			return nil
+		}
+		return r
+	}
+
+	// EXIF dates carry no time zone, they are interpreted in the site's.
+	if t, err := x.DateTime(); err == nil {
+		p.Taken = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+
+	maker, model := str(exif.Make), str(exif.Model)
+	p.Camera = model
+	if maker != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		p.Camera = strings.TrimSpace(maker + " " + model)
+	}
+	p.Lens = str(exif.LensModel)
+
+	if r := rat(exif.ExposureTime); r != nil {
+		f, _ := r.Float64()
+		if f < 1 {
+			p.Exposure = fmt.Sprintf("1/%.0fs", 1/f)
+		} else {
+			p.Exposure = fmt.Sprintf("%gs", f)
+		}
+	}
+	if r := rat(exif.FNumber); r != nil {
+		f, _ := r.Float64()
+		p.Aperture = fmt.Sprintf("f/%g", f)
+	}
+	if r := rat(exif.FocalLength); r != nil {
+		f, _ := r.Float64()
+		p.FocalLength = fmt.Sprintf("%gmm", f)
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		p.ISO, _ = tag.Int(0)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// readGitModTimes runs git log once for the repository containing dir and
+// returns the time of the last commit that touched each file, keyed by
+// absolute path.
+func readGitModTimes(dir string) (map[string]time.Time, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find git repository for %#v: %w", dir, err)
+	}
+	root := strings.TrimSpace(string(out))
+
+	// paths are relative to the repository's root regardless of -C, -z
+	// keeps git from quoting unusual file names. Records are separated by
+	// NUL, commit times are marked by a leading \x01.
+	cmd := exec.Command("git", "-C", dir, "log", "-z", "--format=%x01%cI", "--name-only", "--no-renames", "--", ".")
+	out, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git log for %#v: %w", dir, err)
+	}
+
+	result := map[string]time.Time{}
+	var current time.Time
+	for _, rec := range bytes.Split(out, []byte{0}) {
+		ln := strings.TrimPrefix(string(rec), "\n")
+		switch {
+		case ln == "":
+		case strings.HasPrefix(ln, "\x01"):
+			current, err = time.Parse(time.RFC3339, ln[1:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse commit time %#v: %w", ln[1:], err)
+			}
+		default:
+			fp := filepath.Join(root, filepath.FromSlash(ln))
+			if _, ok := result[fp]; !ok {
+				result[fp] = current
+			}
+		}
+	}
+	verbose("read git modification times for %v files in %#v.", len(result), root)
+
+	return result, nil
+}
+
+func (b *blog) readGitModTimes() error {
+	if !b.Config.GitModified {
+		return nil
+	}
+
+	var err error
+	b.gitModTimes, err = readGitModTimes(b.BaseDirectory)
+	return err
+}
+
+// gitModTime returns the time of the last commit of file f, if git
+// modification times are enabled and the file is tracked.
+func (b *blog) gitModTime(f string) (time.Time, bool) {
+	if b.gitModTimes == nil {
+		return time.Time{}, false
+	}
+
+	fp, err := filepath.Abs(f)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if resolved, err := filepath.EvalSymlinks(fp); err == nil {
+		fp = resolved
+	}
+
+	t, ok := b.gitModTimes[fp]
+	return t, ok
+}
@@ -3,14 +3,20 @@ module github.com/fgeller/mugo
 go 1.20
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/davecgh/go-spew v1.1.1
 	github.com/fgeller/relabs v0.0.0-20201021194441-447ad38d7d9d
 	github.com/gorilla/feeds v1.1.1
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
+	github.com/tdewolff/minify/v2 v2.24.3
 	github.com/yuin/goldmark v1.5.4
 	github.com/yuin/goldmark-meta v1.1.0
+	golang.org/x/image v0.24.0
+	golang.org/x/net v0.35.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/kr/pretty v0.2.1 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	github.com/tdewolff/parse/v2 v2.8.3 // indirect
 )
@@ -33,7 +33,7 @@ func (g *group) URL() string {
 }
 
 func (g *group) RelativeURL() string {
-	return urlJoin("/", g.Name, g.HTMLFileName())
+	return urlJoin(g.Blog.rootPath(), g.Name, g.HTMLFileName())
 }
 
 func (g *group) HTMLFileName() string {
@@ -49,8 +49,14 @@ func (g *group) renderIndex() error {
 		return fmt.Errorf("failed to execute group index template: %w", err)
 	}
 
-	fp := filepath.Join(g.Blog.OutputDirectory, g.Name, g.HTMLFileName())
-	err = os.WriteFile(fp, buf.Bytes(), 0644)
+	dir := filepath.Join(g.Blog.OutputDirectory, g.Name)
+	err = os.MkdirAll(dir, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create group directory [%s] err=%w", dir, err)
+	}
+
+	fp := filepath.Join(dir, g.HTMLFileName())
+	err = g.Blog.writeOutput(fp, buf.Bytes())
 	if err != nil {
 		return fmt.Errorf("failed to write group index file: %w", err)
 	}
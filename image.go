@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultImageQuality = 85
+	defaultImageSizes   = "100vw"
+	defaultWebPEncoder  = "cwebp"
+)
+
+var imgTag = regexp.MustCompile(`<img\s[^>]*>`)
+var htmlAttr = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+
+// imageVariant is a single processed file of an image, Name is the file name
+// in the same directory as the original image. Variants are named like
+// pic@400w.jpg, except for the full size variant that replaces the synced
+// original, cf. variant.
+type imageVariant struct {
+	Name   string
+	Width  int
+	Height int
+
+	cached string
+}
+
+// processedImage holds the variants that the pipeline generated for a source
+// image, both lists are sorted by width and end with the full size variant.
+type processedImage struct {
+	Width    int
+	Height   int
+	Variants []*imageVariant
+	WebP     []*imageVariant
+}
+
+func (pi *processedImage) full() *imageVariant {
+	return pi.Variants[len(pi.Variants)-1]
+}
+
+// responsiveImage resolves the variants of a processed image against the URL
+// of the original image for use in templates, Src refers to the full size
+// variant.
+type responsiveImage struct {
+	Src        string
+	Width      int
+	Height     int
+	Srcset     string
+	WebPSrcset string
+	Sizes      string
+}
+
+func newResponsiveImage(src string, sizes string, pi *processedImage) *responsiveImage {
+	base := src[:strings.LastIndex(src, "/")+1]
+	srcset := func(vs []*imageVariant) string {
+		result := []string{}
+		for _, v := range vs {
+			result = append(result, fmt.Sprintf("%s%s %dw", base, v.Name, v.Width))
+		}
+		return strings.Join(result, ", ")
+	}
+
+	return &responsiveImage{
+		Src:        base + pi.full().Name,
+		Width:      pi.Width,
+		Height:     pi.Height,
+		Srcset:     srcset(pi.Variants),
+		WebPSrcset: srcset(pi.WebP),
+		Sizes:      sizes,
+	}
+}
+
+// HTML renders a picture element with the WebP source, if any, and an img
+// element with the remaining attributes, e.g. alt or title.
+func (ri *responsiveImage) HTML(attrs ...string) template.HTML {
+	var buf strings.Builder
+	esc := template.HTMLEscapeString
+
+	buf.WriteString("<picture>")
+	if ri.WebPSrcset != "" {
+		fmt.Fprintf(&buf, `<source type="image/webp" srcset="%s" sizes="%s" />`, esc(ri.WebPSrcset), esc(ri.Sizes))
+	}
+	fmt.Fprintf(&buf, `<img src="%s" srcset="%s" sizes="%s" width="%d" height="%d"`, esc(ri.Src), esc(ri.Srcset), esc(ri.Sizes), ri.Width, ri.Height)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&buf, ` %s="%s"`, attrs[i], esc(attrs[i+1]))
+	}
+	buf.WriteString(" /></picture>")
+
+	return template.HTML(buf.String())
+}
+
+func isProcessableImage(fn string) bool {
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// imagesConfig returns the configured image settings, or the defaults for
+// images that are processed without the pipeline being enabled, e.g. the
+// thumbnails of galleries.
+func (b *blog) imagesConfig() *imagesConfig {
+	if b.Config.Images == nil {
+		return &imagesConfig{}
+	}
+	return b.Config.Images
+}
+
+func (b *blog) imageCacheDirectory() (string, error) {
+	dir := b.imagesConfig().CacheDirectory
+	if dir == "" {
+		ucd, err := os.UserCacheDir()
+		if err != nil {
+			ucd = os.TempDir()
+		}
+		dir = filepath.Join(ucd, "mugo", "images")
+	}
+	return dir, os.MkdirAll(dir, 0770)
+}
+
+// sourceImage is an image in the base directory, it is only decoded when a
+// variant is missing in the cache.
+type sourceImage struct {
+	path        string
+	byt         []byte
+	key         string
+	format      string
+	orientation int
+	width       int
+	height      int
+	decoded     image.Image
+}
+
+func readSourceImage(src string) (*sourceImage, error) {
+	byt, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(byt)
+	si := &sourceImage{path: src, byt: byt, key: hex.EncodeToString(sum[:16])}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(byt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %#v: %w", src, err)
+	}
+	si.format, si.width, si.height = format, cfg.Width, cfg.Height
+
+	si.orientation = exifOrientation(byt)
+	if si.orientation >= 5 {
+		si.width, si.height = si.height, si.width
+	}
+
+	return si, nil
+}
+
+func (si *sourceImage) image() (image.Image, error) {
+	if si.decoded != nil {
+		return si.decoded, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(si.byt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %#v: %w", si.path, err)
+	}
+	si.decoded = orient(img, si.orientation)
+	return si.decoded, nil
+}
+
+// variant encodes the source image at width w in the source's format and
+// copies it next to dst, the synced copy of the source in the output
+// directory. The full size variant replaces dst to strip EXIF data, unless
+// dst is the source itself when writing in place: the variant is named like
+// the others then and the pages refer to it instead of the original.
+// Variants are cached by the hash of the source so that later builds only
+// copy them.
+func (b *blog) variant(si *sourceImage, dst string, w int) (*imageVariant, error) {
+	cacheDir, err := b.imageCacheDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	quality := b.imagesConfig().Quality
+	if quality <= 0 {
+		quality = defaultImageQuality
+	}
+
+	ext := filepath.Ext(dst)
+	stem := strings.TrimSuffix(filepath.Base(dst), ext)
+
+	h := (si.height*w + si.width/2) / si.width
+	cached := filepath.Join(cacheDir, fmt.Sprintf("%s-%dw-q%d.%s", si.key, w, quality, si.format))
+	v := &imageVariant{Name: fmt.Sprintf("%s@%dw%s", stem, w, ext), Width: w, Height: h, cached: cached}
+	if w == si.width && dst != si.path {
+		v.Name = stem + ext
+	}
+
+	if _, err := os.Stat(cached); os.IsNotExist(err) {
+		img, err := si.image()
+		if err != nil {
+			return nil, err
+		}
+		err = writeImage(cached, resize(img, w, h), si.format, quality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write image variant %#v: %w", cached, err)
+		}
+		verbose("processed image %#v to %#v.", si.path, cached)
+	}
+
+	return v, b.publishVariant(v, dst)
+}
+
+// webpVariant encodes the variant v as WebP with the external encoder, as
+// there is no lossy WebP encoder for Go. WebP variants are always named like
+// pic@400w.webp.
+func (b *blog) webpVariant(v *imageVariant, dst string) (*imageVariant, error) {
+	cfg := b.imagesConfig()
+	quality := cfg.Quality
+	if quality <= 0 {
+		quality = defaultImageQuality
+	}
+	encoder := cfg.WebPEncoder
+	if encoder == "" {
+		encoder = defaultWebPEncoder
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(dst), filepath.Ext(dst))
+	cached := strings.TrimSuffix(v.cached, filepath.Ext(v.cached)) + ".webp"
+	wv := &imageVariant{Name: fmt.Sprintf("%s@%dw.webp", stem, v.Width), Width: v.Width, Height: v.Height, cached: cached}
+
+	if _, err := os.Stat(cached); os.IsNotExist(err) {
+		tmp := cached + ".tmp"
+		out, err := exec.Command(encoder, "-quiet", "-metadata", "none", "-q", strconv.Itoa(quality), v.cached, "-o", tmp).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %#v as webp with %#v: %w %s", v.cached, encoder, err, out)
+		}
+		err = os.Rename(tmp, cached)
+		if err != nil {
+			return nil, err
+		}
+		verbose("encoded image variant %#v as webp.", v.cached)
+	}
+
+	return wv, nil
+}
+
+func (b *blog) publishVariant(v *imageVariant, dst string) error {
+	fn := filepath.Join(filepath.Dir(dst), v.Name)
+	err := os.MkdirAll(filepath.Dir(fn), 0770)
+	if err != nil {
+		return err
+	}
+	b.outputs[fn] = true
+	return copyFile(v.cached, fn)
+}
+
+// processImage generates the configured variants of the source image src and
+// writes them next to dst, cf. variant.
+func (b *blog) processImage(src, dst string) (*processedImage, error) {
+	if pi, ok := b.images[src]; ok {
+		return pi, nil
+	}
+
+	si, err := readSourceImage(src)
+	if err != nil {
+		return nil, err
+	}
+
+	widths := []int{}
+	for _, w := range b.imagesConfig().Widths {
+		if w > 0 && w < si.width {
+			widths = append(widths, w)
+		}
+	}
+	sort.Ints(widths)
+	widths = append(widths, si.width)
+
+	pi := &processedImage{Width: si.width, Height: si.height}
+	for _, w := range widths {
+		v, err := b.variant(si, dst, w)
+		if err != nil {
+			return nil, err
+		}
+		pi.Variants = append(pi.Variants, v)
+	}
+
+	if b.imagesConfig().WebP {
+		err = b.addWebP(pi, dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.images[src] = pi
+	return pi, nil
+}
+
+// addWebP encodes WebP alternatives of all variants. They are only offered
+// if the full size WebP is smaller than the original format, which is rarely
+// the case for already small PNGs, otherwise previously published WebP
+// variants are removed.
+func (b *blog) addWebP(pi *processedImage, dst string) error {
+	wvs := []*imageVariant{}
+	for _, v := range pi.Variants {
+		wv, err := b.webpVariant(v, dst)
+		if err != nil {
+			return err
+		}
+		wvs = append(wvs, wv)
+	}
+
+	if fileSize(wvs[len(wvs)-1].cached) >= fileSize(pi.full().cached) {
+		verbose("skip webp for %#v as it is not smaller than the original.", dst)
+		for _, wv := range wvs {
+			err := os.Remove(filepath.Join(filepath.Dir(dst), wv.Name))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, wv := range wvs {
+		err := b.publishVariant(wv, dst)
+		if err != nil {
+			return err
+		}
+	}
+	pi.WebP = wvs
+	return nil
+}
+
+func fileSize(fn string) int64 {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+func exifOrientation(byt []byte) int {
+	x, err := exif.Decode(bytes.NewReader(byt))
+	if err != nil {
+		return 1
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+	o, err := tag.Int(0)
+	if err != nil {
+		return 1
+	}
+	return o
+}
+
+// orient applies the EXIF orientation to the pixels as the orientation tag is
+// lost when the image is re-encoded.
+func orient(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if o >= 5 {
+		w, h = h, w
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = w-1-y, x
+			case 7:
+				dx, dy = w-1-y, h-1-x
+			case 8:
+				dx, dy = y, h-1-x
+			}
+			result.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return result
+}
+
+func resize(img image.Image, w, h int) image.Image {
+	if img.Bounds().Dx() == w && img.Bounds().Dy() == h {
+		return img
+	}
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(result, result.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return result
+}
+
+func writeImage(fn string, img image.Image, format string, quality int) error {
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(&buf, img)
+	default:
+		err = fmt.Errorf("unsupported image format %#v", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first to never leave a partial file in the cache.
+	tmp := fn + ".tmp"
+	err = os.WriteFile(tmp, buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// copyFile copies src to dst unless dst is a copy of src already.
+func copyFile(src, dst string) error {
+	if sameContent(src, dst) {
+		return nil
+	}
+
+	sf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	df, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer df.Close()
+
+	_, err = io.Copy(df, sf)
+	return err
+}
+
+// sourcePath maps the src of an img element in the html of the md file to
+// the image's path in the base directory, ok is false for external images.
+func (b *blog) sourcePath(src, md string) (string, bool) {
+	if i := strings.IndexAny(src, "?#"); i >= 0 {
+		src = src[:i]
+	}
+
+	var pth string
+	switch {
+	case strings.HasPrefix(src, b.BaseURL):
+		pth = filepath.Join(b.BaseDirectory, filepath.FromSlash(strings.TrimPrefix(src, b.BaseURL)))
+	case strings.Contains(src, "://") || strings.HasPrefix(src, "//") || strings.HasPrefix(src, "data:"):
+		return "", false
+	case strings.HasPrefix(src, "/"):
+		rel := strings.TrimPrefix(src, b.rootPath())
+		pth = filepath.Join(b.BaseDirectory, filepath.FromSlash(rel))
+	default:
+		pth = filepath.Join(filepath.Dir(md), filepath.FromSlash(src))
+	}
+
+	rel, err := filepath.Rel(b.BaseDirectory, pth)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return pth, true
+}
+
+func (b *blog) outputPath(src string) (string, error) {
+	rel, err := filepath.Rel(b.BaseDirectory, src)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(b.OutputDirectory, rel), nil
+}
+
+// responsiveImages rewrites the local img elements in the rendered html of
+// the md file to picture elements with srcsets of the processed variants and
+// their WebP alternatives.
+func (b *blog) responsiveImages(h template.HTML, md string) (template.HTML, error) {
+	if b.Config.Images == nil {
+		return h, nil
+	}
+
+	sizes := b.Config.Images.Sizes
+	if sizes == "" {
+		sizes = defaultImageSizes
+	}
+
+	var err error
+	result := imgTag.ReplaceAllStringFunc(string(h), func(tag string) string {
+		if err != nil {
+			return tag
+		}
+
+		attrs := []string{}
+		var src string
+		for _, m := range htmlAttr.FindAllStringSubmatch(tag, -1) {
+			switch m[1] {
+			case "src":
+				src = m[2]
+			case "srcset", "sizes", "width", "height":
+				return tag
+			default:
+				attrs = append(attrs, m[1], html.UnescapeString(m[2]))
+			}
+		}
+
+		raw := html.UnescapeString(src)
+		pth, ok := b.sourcePath(raw, md)
+		if !ok || !isProcessableImage(pth) || isGenerated(pth) {
+			return tag
+		}
+		if _, serr := os.Stat(pth); serr != nil {
+			log.Printf("skip missing image %#v in %#v", raw, md)
+			return tag
+		}
+
+		dst, perr := b.outputPath(pth)
+		if perr != nil {
+			err = perr
+			return tag
+		}
+		pi, perr := b.processImage(pth, dst)
+		if perr != nil {
+			err = perr
+			return tag
+		}
+
+		return string(newResponsiveImage(raw, sizes, pi).HTML(attrs...))
+	})
+
+	return template.HTML(result), err
+}
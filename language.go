@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// newBlogs creates one blog per configured language. The default language is
+// written to the output directory and base url, other languages to a sub
+// directory named by their code. Languages share the base directory unless
+// they configure their own, source files in a shared base directory are
+// assigned to a language by a suffix like post.de.md.
+func newBlogs(cfg *config) []*blog {
+	if len(cfg.Languages) == 0 {
+		return []*blog{newBlog(cfg)}
+	}
+
+	result := []*blog{}
+	for _, lc := range cfg.languagesDefaultFirst() {
+		lcfg := *cfg
+		if lc.Title != "" {
+			lcfg.Title = lc.Title
+		}
+		if lc.BaseDirectory != "" {
+			lcfg.BaseDirectory = lc.BaseDirectory
+		}
+		if _, ok := locales[lc.Code]; ok {
+			lcfg.Language = lc.Code
+		}
+
+		b := newBlog(&lcfg)
+		b.Language = lc.Code
+		if len(result) > 0 {
+			out := cfg.OutputDirectory
+			if out == "" {
+				out = cfg.BaseDirectory
+			}
+			b.OutputDirectory = filepath.Join(out, lc.Code)
+			b.BaseURL = urlJoin(cfg.BaseURL, lc.Code)
+		}
+		result = append(result, b)
+	}
+
+	for _, b := range result {
+		b.Languages = result
+	}
+
+	return result
+}
+
+func (c *config) languagesDefaultFirst() []*languageConfig {
+	result := []*languageConfig{}
+	for _, lc := range c.Languages {
+		if lc.Code == c.DefaultLanguage {
+			result = append([]*languageConfig{lc}, result...)
+		} else {
+			result = append(result, lc)
+		}
+	}
+	return result
+}
+
+// languageSuffix returns the code of a configured language that md uses as
+// suffix, e.g. de for post.de.md.
+func (b *blog) languageSuffix(md string) string {
+	name := strings.TrimSuffix(filepath.Base(md), ".md")
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	for _, l := range b.Languages {
+		if l.Language == ext {
+			return ext
+		}
+	}
+	return ""
+}
+
+// ownerOf returns the language blog that a source file belongs to: either by
+// its suffix or by the most specific base directory that contains it.
+func (b *blog) ownerOf(md string) *blog {
+	if len(b.Languages) == 0 {
+		return b
+	}
+
+	if code := b.languageSuffix(md); code != "" {
+		for _, l := range b.Languages {
+			if l.Language == code {
+				return l
+			}
+		}
+	}
+
+	var owner *blog
+	for _, l := range b.Languages {
+		rel, err := filepath.Rel(l.BaseDirectory, md)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if owner == nil || len(l.BaseDirectory) > len(owner.BaseDirectory) {
+			owner = l
+		}
+	}
+	return owner
+}
+
+func (b *blog) isOutputDirectory(dir string) bool {
+	if dir == b.OutputDirectory {
+		return true
+	}
+	for _, l := range b.Languages {
+		if dir == l.OutputDirectory {
+			return true
+		}
+	}
+	return false
+}
+
+// isForeignDirectory reports whether the walk of the blog's base directory
+// should skip dir: output directories of all languages, unless dir is the
+// base directory itself as when writing in place, and base directories of
+// other languages nested in this one.
+func (b *blog) isForeignDirectory(dir string) bool {
+	if dir == b.BaseDirectory {
+		return false
+	}
+	if b.isOutputDirectory(dir) {
+		return true
+	}
+	for _, l := range b.Languages {
+		if l != b && dir == l.BaseDirectory {
+			return true
+		}
+	}
+	return false
+}
+
+// isLanguageAsset reports whether the sync should copy the file to the
+// blog's output directory. With multiple languages the md sources are
+// rendered per language rather than published to every language's output
+// directory. The same holds for pages and feeds that another language
+// writes in place into the shared base directory.
+func (b *blog) isLanguageAsset(fn string) bool {
+	if len(b.Languages) == 0 {
+		return true
+	}
+	if filepath.Ext(fn) == ".md" || isRenderedMD(fn) {
+		return false
+	}
+	for _, l := range b.Languages {
+		rel, err := filepath.Rel(l.OutputDirectory, fn)
+		if l == b || err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		switch filepath.Ext(fn) {
+		case ".html", ".xml", ".gz", ".br":
+			return false
+		}
+	}
+	return true
+}
+
+// rootPath is the path of the base url, used for relative urls.
+func (b *blog) rootPath() string {
+	u, err := url.Parse(b.BaseURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// translationKey identifies the translations of an entry by its path relative
+// to the language's base directory, unless set via front matter.
+func (e *entry) translationKey() string {
+	if k := e.Params.String("translation-key"); k != "" {
+		return k
+	}
+
+	rel, err := filepath.Rel(e.Blog.BaseDirectory, e.MDFile)
+	if err != nil {
+		rel = e.MDFile
+	}
+	rel = strings.TrimSuffix(rel, ".md")
+	if code := e.Blog.languageSuffix(e.MDFile); code != "" {
+		rel = strings.TrimSuffix(rel, "."+code)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func linkTranslations(blogs []*blog) {
+	byKey := map[string][]*entry{}
+	for _, b := range blogs {
+		for _, e := range b.Entries {
+			k := e.translationKey()
+			byKey[k] = append(byKey[k], e)
+		}
+	}
+
+	for _, es := range byKey {
+		for _, e := range es {
+			e.Translations = []*entry{}
+			for _, t := range es {
+				if t != e {
+					e.Translations = append(e.Translations, t)
+				}
+			}
+		}
+	}
+}
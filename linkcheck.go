@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultExternalTimeout  = 10 * time.Second
+	defaultExternalCacheTTL = 24 * time.Hour
+)
+
+// linkAttributes lists the attributes per element that refer to other files.
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"link":   {"href"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+}
+
+type brokenLink struct {
+	Page   string // generated html file
+	Link   string
+	Reason string
+	Source string // md file of the page, if any
+	Line   int    // line in Source that contains the link, if found
+}
+
+func (bl *brokenLink) String() string {
+	loc := bl.Page
+	if bl.Source != "" {
+		loc = bl.Source
+		if bl.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", bl.Source, bl.Line)
+		}
+	}
+	return fmt.Sprintf("%s: broken link %#v in %#v: %s", loc, bl.Link, bl.Page, bl.Reason)
+}
+
+type externalResult struct {
+	Status  int       `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+type linkChecker struct {
+	blogs   []*blog
+	sources map[string]string          // html file to md file
+	ids     map[string]map[string]bool // html file to the ids of its elements
+
+	external  bool
+	allowlist []string
+	client    *http.Client
+	cacheFile string
+	cacheTTL  time.Duration
+	cache     map[string]*externalResult
+
+	broken []*brokenLink
+}
+
+// checkLinks implements "mugo check-links": it regenerates the site and
+// verifies that all internal links of the generated html files resolve to
+// output files, including #fragments. External links are only checked when
+// enabled, requests honor HTTP_PROXY so that they can be pointed to a stub
+// server.
+func checkLinks(args []string) error {
+	var cf, allowlist string
+	lc := &linkChecker{
+		sources: map[string]string{},
+		ids:     map[string]map[string]bool{},
+		cache:   map[string]*externalResult{},
+	}
+
+	flags := flag.NewFlagSet("mugo check-links", flag.ContinueOnError)
+	flags.StringVar(&cf, "config", "", "Path to JSON config file (required).")
+	flags.BoolVar(&lc.external, "external", false, "Check external links too.")
+	flags.StringVar(&allowlist, "allowlist", "", "Path to file with URL prefixes that are not checked, one per line.")
+	flags.StringVar(&lc.cacheFile, "cache", "", "Path to JSON file that caches results of external checks.")
+	flags.DurationVar(&lc.cacheTTL, "cache-ttl", defaultExternalCacheTTL, "Duration for which cached results of external checks are used.")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if cf == "" {
+		return fmt.Errorf("config is required.")
+	}
+
+	if allowlist != "" {
+		lc.allowlist, err = readAllowlist(allowlist)
+		if err != nil {
+			return err
+		}
+	}
+	err = lc.readCache()
+	if err != nil {
+		return err
+	}
+	lc.client = &http.Client{Timeout: defaultExternalTimeout}
+
+	cfg, err := readConfigFile(cf)
+	if err != nil {
+		return err
+	}
+	lc.blogs = newBlogs(cfg)
+	err = regenerate(lc.blogs)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range lc.blogs {
+		for _, e := range append(b.Entries, b.DraftEntries...) {
+			lc.sources[e.HTMLFile] = e.MDFile
+		}
+		for _, t := range b.Tops {
+			lc.sources[t.HTMLFile] = t.MDFile
+		}
+	}
+
+	for _, b := range lc.blogs {
+		err = lc.checkBlog(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = lc.writeCache()
+	if err != nil {
+		return err
+	}
+
+	return lc.report()
+}
+
+func readAllowlist(fn string) ([]string, error) {
+	byt, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowlist %#v: %w", fn, err)
+	}
+	result := []string{}
+	for _, l := range strings.Split(string(byt), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "#") {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func (lc *linkChecker) readCache() error {
+	if lc.cacheFile == "" {
+		return nil
+	}
+	byt, err := os.ReadFile(lc.cacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(byt, &lc.cache)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal link cache %#v: %w", lc.cacheFile, err)
+	}
+	return nil
+}
+
+func (lc *linkChecker) writeCache() error {
+	if lc.cacheFile == "" {
+		return nil
+	}
+	byt, err := json.MarshalIndent(lc.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lc.cacheFile, byt, 0644)
+}
+
+func (lc *linkChecker) report() error {
+	byLocation := func(i, j int) bool {
+		a, b := lc.broken[i], lc.broken[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Page < b.Page
+	}
+	sort.SliceStable(lc.broken, byLocation)
+
+	for _, bl := range lc.broken {
+		fmt.Println(bl)
+	}
+	if len(lc.broken) > 0 {
+		return fmt.Errorf("found %v broken links.", len(lc.broken))
+	}
+	fmt.Println("found no broken links.")
+	return nil
+}
+
+func (lc *linkChecker) checkBlog(b *blog) error {
+	walker := func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && pth != b.OutputDirectory && b.isOutputDirectory(pth) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || filepath.Ext(pth) != ".html" {
+			return nil
+		}
+		return lc.checkPage(b, pth)
+	}
+	return filepath.Walk(b.OutputDirectory, walker)
+}
+
+func (lc *linkChecker) checkPage(b *blog, page string) error {
+	doc, err := parseHTMLFile(page)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(b.OutputDirectory, page)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(urlJoin(b.BaseURL, filepath.ToSlash(rel)))
+	if err != nil {
+		return err
+	}
+
+	for _, link := range findLinks(doc) {
+		reason := lc.checkLink(base, link)
+		if reason == "" {
+			continue
+		}
+		bl := &brokenLink{Page: page, Link: link, Reason: reason, Source: lc.sources[page]}
+		if bl.Source != "" {
+			bl.Line = findSourceLine(bl.Source, base, link)
+		}
+		lc.broken = append(lc.broken, bl)
+	}
+
+	return nil
+}
+
+func parseHTMLFile(fn string) (*html.Node, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	doc, err := html.Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html %#v: %w", fn, err)
+	}
+	return doc, nil
+}
+
+func findLinks(doc *html.Node) []string {
+	result := []string{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				for _, name := range linkAttributes[n.Data] {
+					if a.Key != name {
+						continue
+					}
+					if name == "srcset" {
+						for _, c := range strings.Split(a.Val, ",") {
+							if fs := strings.Fields(c); len(fs) > 0 {
+								result = append(result, fs[0])
+							}
+						}
+						continue
+					}
+					result = append(result, strings.TrimSpace(a.Val))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return result
+}
+
+func findIDs(doc *html.Node) map[string]bool {
+	result := map[string]bool{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				if a.Key == "id" || (a.Key == "name" && n.Data == "a") {
+					result[a.Val] = true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return result
+}
+
+// checkLink returns the reason why the link is broken, or the empty string.
+func (lc *linkChecker) checkLink(base *url.URL, link string) string {
+	if link == "" {
+		return ""
+	}
+	for _, scheme := range []string{"mailto:", "tel:", "javascript:", "data:"} {
+		if strings.HasPrefix(strings.ToLower(link), scheme) {
+			return ""
+		}
+	}
+
+	ref, err := url.Parse(link)
+	if err != nil {
+		return fmt.Sprintf("invalid url: %v", err)
+	}
+	target := base.ResolveReference(ref)
+
+	b, fn := lc.outputFile(target)
+	if b == nil {
+		return lc.checkExternal(target)
+	}
+
+	fi, err := os.Stat(fn)
+	if err == nil && fi.IsDir() {
+		fn = filepath.Join(fn, "index.html")
+		fi, err = os.Stat(fn)
+	}
+	if err != nil {
+		return "target does not exist"
+	}
+
+	if target.Fragment == "" || filepath.Ext(fn) != ".html" {
+		return ""
+	}
+	ids, ok := lc.ids[fn]
+	if !ok {
+		doc, err := parseHTMLFile(fn)
+		if err != nil {
+			return err.Error()
+		}
+		ids = findIDs(doc)
+		lc.ids[fn] = ids
+	}
+	if !ids[target.Fragment] {
+		return fmt.Sprintf("anchor #%s does not exist", target.Fragment)
+	}
+	return ""
+}
+
+// outputFile maps an internal url to the file in the output directory of the
+// blog with the most specific base url, it returns a nil blog for external
+// urls.
+func (lc *linkChecker) outputFile(target *url.URL) (*blog, string) {
+	var owner *blog
+	var rel string
+	for _, b := range lc.blogs {
+		bu, err := url.Parse(b.BaseURL)
+		if err != nil || bu.Host != target.Host {
+			continue
+		}
+		bp := strings.TrimSuffix(bu.Path, "/") + "/"
+		tp := target.Path
+		if tp == strings.TrimSuffix(bp, "/") {
+			tp = bp
+		}
+		if !strings.HasPrefix(tp, bp) {
+			continue
+		}
+		if owner == nil || len(b.BaseURL) > len(owner.BaseURL) {
+			owner, rel = b, strings.TrimPrefix(tp, bp)
+		}
+	}
+	if owner == nil {
+		return nil, ""
+	}
+	return owner, filepath.Join(owner.OutputDirectory, filepath.FromSlash(path.Clean("/"+rel)))
+}
+
+func (lc *linkChecker) checkExternal(target *url.URL) string {
+	if !lc.external || (target.Scheme != "http" && target.Scheme != "https") {
+		return ""
+	}
+
+	u := *target
+	u.Fragment = ""
+	raw := u.String()
+	for _, prefix := range lc.allowlist {
+		if strings.HasPrefix(raw, prefix) {
+			return ""
+		}
+	}
+
+	res, ok := lc.cache[raw]
+	if !ok || time.Since(res.Checked) > lc.cacheTTL {
+		res = lc.request(raw)
+		lc.cache[raw] = res
+	}
+
+	switch {
+	case res.Error != "":
+		return res.Error
+	case res.Status >= 400:
+		return fmt.Sprintf("external link returned status %v", res.Status)
+	}
+	return ""
+}
+
+func (lc *linkChecker) request(raw string) *externalResult {
+	result := &externalResult{Checked: time.Now()}
+
+	resp, err := lc.client.Head(raw)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = lc.client.Get(raw)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	verbose("checked external link %#v: %v", raw, resp.StatusCode)
+	return result
+}
+
+// findSourceLine returns the first line of the md file that contains the
+// link as written in the html, or relative to the page as the md source
+// usually refers to it before links are resolved. It returns 0 if there is
+// no such line, e.g. for links generated by templates.
+func findSourceLine(md string, base *url.URL, link string) int {
+	byt, err := os.ReadFile(md)
+	if err != nil {
+		return 0
+	}
+
+	candidates := []string{link}
+	if rel := relativeLink(base, link); rel != "" && rel != link {
+		candidates = append(candidates, rel)
+	}
+
+	lines := strings.Split(string(byt), "\n")
+	for _, c := range candidates {
+		for i, l := range lines {
+			if strings.Contains(l, c) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// relativeLink returns link relative to the page at base if it points into the
+// page's directory, or "" otherwise.
+func relativeLink(base *url.URL, link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	u = base.ResolveReference(u)
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return ""
+	}
+
+	rel := ""
+	if u.Path != base.Path {
+		dir := path.Dir(base.Path) + "/"
+		if !strings.HasPrefix(u.Path, dir) || u.Path == dir {
+			return ""
+		}
+		rel = strings.TrimPrefix(u.Path, dir)
+	}
+	if u.Fragment != "" {
+		rel += "#" + u.Fragment
+	}
+	return rel
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+type locale struct {
+	Months        [12]string
+	ShortMonths   [12]string
+	Weekdays      [7]string
+	ShortWeekdays [7]string
+}
+
+// locales are keyed by the language config, weekdays start with Sunday as
+// for time.Weekday.
+var locales = map[string]*locale{
+	"en": {
+		Months:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		ShortMonths:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		Weekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		ShortWeekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	},
+	"de": {
+		Months:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths:   [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		Weekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		ShortWeekdays: [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+	},
+}
+
+// layoutNames are replaced by placeholders before formatting, so that they
+// can be replaced by the localized names afterwards. Longer names come first
+// as they contain the shorter ones.
+var layoutNames = []struct {
+	layout      string
+	placeholder string
+}{
+	{"January", "\x01"},
+	{"Jan", "\x02"},
+	{"Monday", "\x03"},
+	{"Mon", "\x04"},
+}
+
+func (l *locale) format(t time.Time, layout string) string {
+	for _, ln := range layoutNames {
+		layout = strings.ReplaceAll(layout, ln.layout, ln.placeholder)
+	}
+
+	return strings.NewReplacer(
+		"\x01", l.Months[t.Month()-1],
+		"\x02", l.ShortMonths[t.Month()-1],
+		"\x03", l.Weekdays[t.Weekday()],
+		"\x04", l.ShortWeekdays[t.Weekday()],
+	).Replace(t.Format(layout))
+}
+
+func (l *locale) MonthName(t time.Time) string {
+	return l.Months[t.Month()-1]
+}
+
+func (l *locale) WeekdayName(t time.Time) string {
+	return l.Weekdays[t.Weekday()]
+}
@@ -7,15 +7,25 @@ import (
 	"log"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check-links" {
+		err := checkLinks(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	cfg, err := readConfig()
 	fail(err)
 
-	lg := newBlog(cfg)
-	err = lg.regenerate()
+	lgs := newBlogs(cfg)
+	err = regenerate(lgs)
 	fail(err)
 }
 
@@ -26,21 +36,90 @@ type config struct {
 	OutputExcludes  []string `json:"output-excludes"`
 	BaseURL         string   `json:"base-url"`
 
-	SitemapFile string `json:"sitemap-file"`
+	SitemapFile   string `json:"sitemap-file"`
+	DataDirectory string `json:"data-directory"`
+
+	// PageDirectories are relative to the base directory, md files below
+	// them are rendered as static pages with the top template.
+	PageDirectories []string `json:"page-directories"`
+
+	TagAliases map[string]string `json:"tag-aliases"`
 
 	ResolveRelativeLinks bool `json:"resolve-relative-links"`
+	GitModified          bool `json:"git-modified"`
+
+	Timezone string `json:"timezone"`
+	Language string `json:"language"`
+
+	Languages       []*languageConfig `json:"languages"`
+	DefaultLanguage string            `json:"default-language"`
+
+	WordsPerMinute int `json:"words-per-minute"`
+	ExcerptLength  int `json:"excerpt-length"`
+	RelatedCount   int `json:"related-count"`
+
+	Taxonomies []*taxonomyConfig `json:"taxonomies"`
+
+	Menus map[string][]*menuItemConfig `json:"menus"`
+
+	Authors       map[string]*authorConfig `json:"authors"`
+	AuthorsFile   string                   `json:"authors-file"`
+	DefaultAuthor string                   `json:"default-author"`
+
+	FrontMatterSchema *frontMatterSchema `json:"front-matter-schema"`
 
-	Templates   *templatesConfig `json:"templates"`
-	Feed        *feedConfig      `json:"feed"`
-	ExpandTilde bool             `json:"expand-tilde"`
+	Templates   *templatesConfig   `json:"templates"`
+	Feed        *feedConfig        `json:"feed"`
+	Minify      bool               `json:"minify"`
+	Precompress *precompressConfig `json:"precompress"`
+	Images      *imagesConfig      `json:"images"`
+	Assets      *assetsConfig      `json:"assets"`
+	ExpandTilde bool               `json:"expand-tilde"`
+
+	location *time.Location
 }
 
 type templatesConfig struct {
-	Main  string `json:"main"`
-	Top   string `json:"top"`
-	Group string `json:"group"`
-	Tags  string `json:"tags"`
-	Entry string `json:"entry"`
+	Main     string `json:"main"`
+	Top      string `json:"top"`
+	Group    string `json:"group"`
+	Tags     string `json:"tags"`
+	Entry    string `json:"entry"`
+	Series   string `json:"series"`
+	Archive  string `json:"archive"`
+	TagIndex string `json:"tag-index"`
+	Taxonomy string `json:"taxonomy"`
+	Term     string `json:"term"`
+	Author   string `json:"author"`
+
+	Shortcodes string `json:"shortcodes"`
+}
+
+type taxonomyConfig struct {
+	Name string `json:"name"`
+	Feed bool   `json:"feed"`
+}
+
+type menuItemConfig struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Weight     int    `json:"weight"`
+	Parent     string `json:"parent"`
+}
+
+type languageConfig struct {
+	Code          string `json:"code"`
+	Title         string `json:"title"`
+	BaseDirectory string `json:"base-directory"`
+}
+
+type authorConfig struct {
+	Name   string            `json:"name"`
+	Bio    string            `json:"bio"`
+	Avatar string            `json:"avatar"`
+	Email  string            `json:"email"`
+	Links  map[string]string `json:"links"`
 }
 
 type feedConfig struct {
@@ -53,6 +132,36 @@ type feedConfig struct {
 	Description string `json:"description"`
 }
 
+// imagesConfig enables the processing of local images: resized variants at
+// the given widths, optionally WebP alternatives, all without EXIF data. WebP
+// is encoded by an external encoder that is compatible with cwebp.
+type imagesConfig struct {
+	Widths         []int  `json:"widths"`
+	Quality        int    `json:"quality"`
+	WebP           bool   `json:"webp"`
+	WebPEncoder    string `json:"webp-encoder"`
+	Sizes          string `json:"sizes"`
+	CacheDirectory string `json:"cache-directory"`
+	ThumbnailWidth int    `json:"thumbnail-width"`
+}
+
+// assetsConfig enables the processing of stylesheets and scripts. Bundles
+// map an output name, e.g. "css/site.css", to the source files relative to
+// the base directory that are concatenated in order.
+type assetsConfig struct {
+	Bundles     map[string][]string `json:"bundles"`
+	Minify      bool                `json:"minify"`
+	Fingerprint bool                `json:"fingerprint"`
+}
+
+// precompressConfig enables writing .gz and .br siblings for text outputs of
+// at least MinSize bytes.
+type precompressConfig struct {
+	Gzip    bool `json:"gzip"`
+	Brotli  bool `json:"brotli"`
+	MinSize int  `json:"min-size"`
+}
+
 // dirty. i know.
 func (c *config) expandTilde() error {
 	if !c.ExpandTilde {
@@ -69,12 +178,28 @@ func (c *config) expandTilde() error {
 	expand(&c.BaseDirectory)
 	expand(&c.OutputDirectory)
 	expand(&c.SitemapFile)
+	expand(&c.AuthorsFile)
+	expand(&c.DataDirectory)
+	for _, lc := range c.Languages {
+		expand(&lc.BaseDirectory)
+	}
+	if c.Images != nil {
+		expand(&c.Images.CacheDirectory)
+		expand(&c.Images.WebPEncoder)
+	}
 	if c.Templates != nil {
 		expand(&c.Templates.Main)
 		expand(&c.Templates.Top)
 		expand(&c.Templates.Tags)
 		expand(&c.Templates.Group)
 		expand(&c.Templates.Entry)
+		expand(&c.Templates.Series)
+		expand(&c.Templates.Archive)
+		expand(&c.Templates.TagIndex)
+		expand(&c.Templates.Taxonomy)
+		expand(&c.Templates.Term)
+		expand(&c.Templates.Author)
+		expand(&c.Templates.Shortcodes)
 	}
 	return nil
 }
@@ -89,15 +214,86 @@ func (c *config) validate() error {
 	if c.BaseURL == "" {
 		return fmt.Errorf("base-url is required")
 	}
+	if c.Timezone != "" {
+		var err error
+		c.location, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	if _, ok := locales[c.Language]; c.Language != "" && !ok {
+		return fmt.Errorf("unsupported language %#v", c.Language)
+	}
+	codes := map[string]bool{}
+	for _, lc := range c.Languages {
+		if lc.Code == "" {
+			return fmt.Errorf("language code is required")
+		}
+		if codes[lc.Code] {
+			return fmt.Errorf("language %#v is configured twice", lc.Code)
+		}
+		codes[lc.Code] = true
+	}
+	if c.DefaultLanguage != "" && !codes[c.DefaultLanguage] {
+		return fmt.Errorf("default-language %#v is not configured in languages", c.DefaultLanguage)
+	}
+	if c.FrontMatterSchema != nil {
+		err := c.FrontMatterSchema.compile()
+		if err != nil {
+			return fmt.Errorf("invalid front-matter-schema: %w", err)
+		}
+	}
+	taxonomies := map[string]bool{}
+	for _, tc := range c.Taxonomies {
+		if tc.Name == "" {
+			return fmt.Errorf("taxonomy name is required")
+		}
+		if !taxonomyName.MatchString(tc.Name) {
+			return fmt.Errorf("taxonomy name %#v may only contain lower case letters, digits, - and _", tc.Name)
+		}
+		if reservedTaxonomyNames[tc.Name] {
+			return fmt.Errorf("taxonomy name %#v is reserved", tc.Name)
+		}
+		if codes[tc.Name] {
+			return fmt.Errorf("taxonomy name %#v is used as language code", tc.Name)
+		}
+		for _, pd := range c.PageDirectories {
+			if filepath.Clean(pd) == tc.Name {
+				return fmt.Errorf("taxonomy name %#v is used as page directory", tc.Name)
+			}
+		}
+		if taxonomies[tc.Name] {
+			return fmt.Errorf("taxonomy %#v is configured twice", tc.Name)
+		}
+		taxonomies[tc.Name] = true
+	}
 	return nil
 }
 
+func (c *config) Location() *time.Location {
+	if c.location == nil {
+		return time.UTC
+	}
+	return c.location
+}
+
+func (c *config) Locale() *locale {
+	if l, ok := locales[c.Language]; ok {
+		return l
+	}
+	return locales["en"]
+}
+
 func readConfig() (*config, error) {
 	cf, err := readFlags()
 	if err != nil {
 		return nil, err
 	}
 
+	return readConfigFile(cf)
+}
+
+func readConfigFile(cf string) (*config, error) {
 	bt, err := os.ReadFile(cf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %#v: %w", cf, err)
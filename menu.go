@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type menuItem struct {
+	Identifier string
+	Name       string
+	URL        string
+	Weight     int
+	Parent     string
+	Children   []*menuItem
+
+	// Page is the top or entry that added the item via front matter.
+	Page interface{}
+
+	blog *blog
+}
+
+type menu []*menuItem
+
+type urler interface {
+	URL() string
+}
+
+// IsActive reports whether the item links to page, which is any value with a
+// URL method like the dot of every template.
+func (m *menuItem) IsActive(page interface{}) bool {
+	p, ok := page.(urler)
+	if !ok {
+		return false
+	}
+	return m.blog.normalizeURL(m.URL) == m.blog.normalizeURL(p.URL())
+}
+
+func (m *menuItem) HasActiveChild(page interface{}) bool {
+	for _, c := range m.Children {
+		if c.IsActive(page) || c.HasActiveChild(page) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *menuItem) HasChildren() bool {
+	return len(m.Children) > 0
+}
+
+func (b *blog) normalizeURL(u string) string {
+	return strings.TrimSuffix(b.absURL(u), "index.html")
+}
+
+func sortMenu(items menu) {
+	byWeight := func(i, j int) bool {
+		if items[i].Weight != items[j].Weight {
+			return items[i].Weight < items[j].Weight
+		}
+		return items[i].Name < items[j].Name
+	}
+	sort.SliceStable(items, byWeight)
+	for _, it := range items {
+		sortMenu(it.Children)
+	}
+}
+
+// pageMenuItems reads the menu front matter key, which is either the name of a
+// menu, a list of names, or a map from menu names to item settings.
+func (b *blog) pageMenuItems(ps params, title, url string, weight int, page interface{}) (map[string]*menuItem, error) {
+	result := map[string]*menuItem{}
+	newItem := func() *menuItem {
+		return &menuItem{Identifier: url, Name: title, URL: url, Weight: weight, Page: page, blog: b}
+	}
+
+	switch raw := ps["menu"].(type) {
+	case nil:
+	case string:
+		result[raw] = newItem()
+	case []interface{}:
+		for _, v := range raw {
+			result[fmt.Sprint(v)] = newItem()
+		}
+	case map[string]interface{}:
+		for name, v := range raw {
+			it := newItem()
+			settings := params{}
+			if m, ok := v.(map[string]interface{}); ok {
+				settings = params(m)
+			}
+			if settings.Has("name") {
+				it.Name = settings.String("name")
+			}
+			if settings.Has("weight") {
+				it.Weight = settings.Int("weight")
+			}
+			if settings.Has("identifier") {
+				it.Identifier = settings.String("identifier")
+			}
+			it.Parent = settings.String("parent")
+			result[name] = it
+		}
+	default:
+		return nil, fmt.Errorf("menu is not passed as string, array or map")
+	}
+
+	return result, nil
+}
+
+func (b *blog) findMenus() error {
+	items := map[string][]*menuItem{}
+
+	for name, ics := range b.Config.Menus {
+		for _, ic := range ics {
+			it := &menuItem{
+				Identifier: ic.Identifier,
+				Name:       ic.Name,
+				URL:        b.absURL(ic.URL),
+				Weight:     ic.Weight,
+				Parent:     ic.Parent,
+				blog:       b,
+			}
+			if it.Identifier == "" {
+				it.Identifier = it.Name
+			}
+			items[name] = append(items[name], it)
+		}
+	}
+
+	for _, t := range b.Tops {
+		pis, err := b.pageMenuItems(t.Params, t.Title, t.URL(), t.Weight, t)
+		if err != nil {
+			return fmt.Errorf("%s in %#v", err, t.MDFile)
+		}
+		for name, it := range pis {
+			items[name] = append(items[name], it)
+		}
+	}
+
+	for _, e := range b.Entries {
+		pis, err := b.pageMenuItems(e.Params, e.Title, e.URL(), e.Weight, e)
+		if err != nil {
+			return fmt.Errorf("%s in %#v", err, e.MDFile)
+		}
+		for name, it := range pis {
+			items[name] = append(items[name], it)
+		}
+	}
+
+	b.Menus = map[string]menu{}
+	for name, its := range items {
+		byID := map[string]*menuItem{}
+		for _, it := range its {
+			byID[it.Identifier] = it
+		}
+
+		for _, it := range its {
+			seen := map[*menuItem]bool{}
+			for p, ok := it, true; ok && p.Parent != ""; p, ok = byID[p.Parent] {
+				if seen[p] {
+					return fmt.Errorf("parents of item %#v in menu %#v form a cycle", it.Identifier, name)
+				}
+				seen[p] = true
+			}
+		}
+
+		m := menu{}
+		for _, it := range its {
+			parent, ok := byID[it.Parent]
+			if it.Parent != "" && ok {
+				parent.Children = append(parent.Children, it)
+			} else {
+				m = append(m, it)
+			}
+		}
+		sortMenu(m)
+		b.Menus[name] = m
+	}
+
+	return nil
+}
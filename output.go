@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/andybalholm/brotli"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/json"
+	"github.com/tdewolff/minify/v2/svg"
+	"github.com/tdewolff/minify/v2/xml"
+)
+
+// outputMediaTypes lists the generated files that writeOutput minifies,
+// stylesheets and scripts are minified by the asset pipeline.
+var outputMediaTypes = map[string]string{
+	".html": "text/html",
+	".xml":  "text/xml",
+}
+
+// generatedName matches the names of files that are derived from a source in
+// the base directory, e.g. image variants like pic@400w.jpg or fingerprinted
+// assets like style.0123456789.css. They end up next to their source when
+// writing in place and must not be taken for sources themselves.
+var generatedName = regexp.MustCompile(`(@\d+w\.\w+|\.[0-9a-f]{10}\.(css|js))$`)
+
+func isGenerated(fn string) bool {
+	return generatedName.MatchString(filepath.Base(fn))
+}
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true, KeepQuotes: true})
+	m.AddFunc("image/svg+xml", svg.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("^(application|text)/(x-)?(java|ecma)script$"), js.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]json$"), json.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]xml$"), xml.Minify)
+	return m
+}
+
+// writeOutput writes all generated files, minifying html, feeds and the
+// sitemap when enabled in the config. Files with unchanged content are not
+// rewritten so that their modification time tells whether they changed.
+func (b *blog) writeOutput(fn string, content []byte) error {
+	if mt, ok := outputMediaTypes[filepath.Ext(fn)]; ok && b.Config.Minify {
+		var buf bytes.Buffer
+		err := b.minifier.Minify(mt, &buf, bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("failed to minify %#v: %w", fn, err)
+		}
+		b.minifiedFiles++
+		b.minifiedBytes += int64(len(content) - buf.Len())
+		content = buf.Bytes()
+	}
+
+	b.outputs[fn] = true
+	if existing, err := os.ReadFile(fn); err == nil && bytes.Equal(existing, content) {
+		return nil
+	}
+	return os.WriteFile(fn, content, 0644)
+}
+
+// sameContent reports whether both files exist and have the same content.
+func sameContent(a, b string) bool {
+	afi, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bfi, err := os.Stat(b)
+	if err != nil || afi.Size() != bfi.Size() {
+		return false
+	}
+
+	ac, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	bc, err := os.ReadFile(b)
+	return err == nil && bytes.Equal(ac, bc)
+}
+
+func (b *blog) reportMinified() {
+	if !b.Config.Minify {
+		return
+	}
+	verbose("minified %v files and saved %v bytes.", b.minifiedFiles, b.minifiedBytes)
+}
+
+const defaultPrecompressMinSize = 1024
+
+// precompressExtensions lists the text outputs that get compressed siblings.
+var precompressExtensions = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".xml":  true,
+	".json": true,
+	".svg":  true,
+}
+
+type compressor struct {
+	ext      string
+	compress func(w io.Writer, src []byte) error
+}
+
+func gzipCompress(w io.Writer, src []byte) error {
+	zw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	_, err = zw.Write(src)
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func brotliCompress(w io.Writer, src []byte) error {
+	bw := brotli.NewWriterLevel(w, brotli.BestCompression)
+	_, err := bw.Write(src)
+	if err != nil {
+		return err
+	}
+	return bw.Close()
+}
+
+// precompressOutputs writes .gz and .br siblings for the text outputs so that
+// the web server can serve them without compressing on the fly. Siblings that
+// are newer than their output are left alone, siblings of outputs below the
+// size threshold are removed.
+func (b *blog) precompressOutputs() error {
+	cfg := b.Config.Precompress
+	if cfg == nil {
+		return nil
+	}
+
+	compressors := []*compressor{}
+	if cfg.Gzip {
+		compressors = append(compressors, &compressor{".gz", gzipCompress})
+	}
+	if cfg.Brotli {
+		compressors = append(compressors, &compressor{".br", brotliCompress})
+	}
+
+	minSize := cfg.MinSize
+	if minSize <= 0 {
+		minSize = defaultPrecompressMinSize
+	}
+
+	count := 0
+	for fn := range b.outputs {
+		if !precompressExtensions[filepath.Ext(fn)] {
+			continue
+		}
+		info, err := os.Stat(fn)
+		if err != nil {
+			return fmt.Errorf("failed to precompress outputs: %w", err)
+		}
+
+		for _, c := range compressors {
+			sibling := fn + c.ext
+			if info.Size() < int64(minSize) {
+				os.Remove(sibling)
+				continue
+			}
+			if sfi, err := os.Stat(sibling); err == nil && !sfi.ModTime().Before(info.ModTime()) {
+				continue
+			}
+
+			src, err := os.ReadFile(fn)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			err = c.compress(&buf, src)
+			if err != nil {
+				return fmt.Errorf("failed to compress %#v: %w", fn, err)
+			}
+			err = os.WriteFile(sibling, buf.Bytes(), 0644)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+	}
+	verbose("wrote %v precompressed files.", count)
+
+	return nil
+}
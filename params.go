@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+)
+
+// params holds the complete front matter of an entry or top, the typed
+// accessors return the zero value for missing keys or mismatching types.
+type params map[string]interface{}
+
+func newParams(header map[string]interface{}) params {
+	result := params{}
+	for k, v := range header {
+		result[k] = normalizeValue(v)
+	}
+	return result
+}
+
+// normalizeValue converts the map[interface{}]interface{} values of the yaml
+// decoder so that templates can index them by string keys.
+func normalizeValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, mv := range tv {
+			result[fmt.Sprint(k)] = normalizeValue(mv)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, mv := range tv {
+			result[k] = normalizeValue(mv)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(tv))
+		for _, sv := range tv {
+			result = append(result, normalizeValue(sv))
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func (p params) Get(key string) interface{} {
+	return p[key]
+}
+
+func (p params) Has(key string) bool {
+	_, ok := p[key]
+	return ok
+}
+
+func (p params) String(key string) string {
+	switch v := p[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (p params) Bool(key string) bool {
+	v, _ := p[key].(bool)
+	return v
+}
+
+func (p params) Int(key string) int {
+	n, _ := toFloat(p[key])
+	return int(n)
+}
+
+func (p params) Float(key string) float64 {
+	n, _ := toFloat(p[key])
+	return n
+}
+
+func (p params) Strings(key string) []string {
+	switch v := p[key].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, sv := range v {
+			result = append(result, fmt.Sprint(sv))
+		}
+		return result
+	default:
+		return []string{}
+	}
+}
+
+func (p params) Map(key string) map[string]interface{} {
+	v, _ := p[key].(map[string]interface{})
+	return v
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// resource is a file that lives next to an entry's md file in its directory,
+// e.g. images or attachments. syncAssets copies it to the output directory
+// so that it is available under the same relative path as the entry.
+type resource struct {
+	Name string // file name in the entry's directory
+	Path string
+	Type string // MIME type, e.g. "image/jpeg"
+	Kind string // major MIME type, e.g. "image"
+	Size int64
+
+	// Image is set for processed images when the image pipeline is enabled.
+	Image *responsiveImage
+	// published is the name of the full size variant that is published for a
+	// processed image, e.g. without EXIF data when writing in place.
+	published string
+
+	Entry *entry
+}
+
+func (r *resource) URL() string {
+	return urlJoin(r.Entry.Blog.BaseURL, r.Entry.Group(), r.Entry.Dir(), r.fileName())
+}
+
+func (r *resource) RelativeURL() string {
+	return urlJoin(r.Entry.Blog.rootPath(), r.Entry.Group(), r.Entry.Dir(), r.fileName())
+}
+
+func (r *resource) fileName() string {
+	if r.published != "" {
+		return r.published
+	}
+	return r.Name
+}
+
+// HumanSize formats the size for download lists, e.g. "1.2 MB".
+func (r *resource) HumanSize() string {
+	const unit = 1000
+	if r.Size < unit {
+		return fmt.Sprintf("%d B", r.Size)
+	}
+	div, exp := int64(unit), 0
+	for n := r.Size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(r.Size)/float64(div), "kMGTPE"[exp])
+}
+
+// readResources reads the files in the entry's own directory, sub
+// directories like galleries and the outputs that mugo writes next to the md
+// file are left out.
+func (e *entry) readResources() error {
+	dir := filepath.Dir(e.MDFile)
+	e.Resources = []*resource{}
+
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read resources of %#v: %w", e.MDFile, err)
+	}
+
+	for _, de := range des {
+		pth := filepath.Join(dir, de.Name())
+		if de.IsDir() || strings.HasPrefix(de.Name(), ".") || !isResource(pth) {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			return fmt.Errorf("failed to read resources of %#v: %w", e.MDFile, err)
+		}
+		r := newResource(e, pth, de.Name(), info.Size())
+		e.Resources = append(e.Resources, r)
+		err = r.processImage()
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(e.Resources, func(i, j int) bool { return e.Resources[i].Name < e.Resources[j].Name })
+	return nil
+}
+
+// isResource reports whether pth is a source file rather than an md file, the
+// captions of a gallery or something mugo generated from a source when
+// writing in place.
+func isResource(pth string) bool {
+	switch filepath.Ext(pth) {
+	case ".md":
+		return false
+	case ".gz", ".br":
+		orig := pth[:len(pth)-len(filepath.Ext(pth))]
+		if isRenderedMD(orig) || isGenerated(orig) {
+			return false
+		}
+	}
+	return filepath.Base(pth) != captionsFile && !isRenderedMD(pth) && !isGenerated(pth)
+}
+
+// isRenderedMD reports whether pth is the html output of a sibling md file,
+// which is the case when the output directory is the base directory.
+func isRenderedMD(pth string) bool {
+	if filepath.Ext(pth) != ".html" {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSuffix(pth, ".html") + ".md")
+	return err == nil
+}
+
+func newResource(e *entry, pth, name string, size int64) *resource {
+	r := &resource{Name: name, Path: pth, Size: size, Entry: e}
+
+	r.Type = mime.TypeByExtension(strings.ToLower(filepath.Ext(pth)))
+	if r.Type == "" {
+		r.Type = "application/octet-stream"
+	}
+	r.Type, _, _ = strings.Cut(r.Type, ";")
+	r.Kind, _, _ = strings.Cut(r.Type, "/")
+
+	return r
+}
+
+func (r *resource) processImage() error {
+	cfg := r.Entry.Blog.Config.Images
+	if cfg == nil || !isProcessableImage(r.Path) {
+		return nil
+	}
+
+	dst, err := r.Entry.Blog.outputPath(r.Path)
+	if err != nil {
+		return err
+	}
+	pi, err := r.Entry.Blog.processImage(r.Path, dst)
+	if err != nil {
+		return err
+	}
+
+	sizes := cfg.Sizes
+	if sizes == "" {
+		sizes = defaultImageSizes
+	}
+	r.Image = newResponsiveImage(r.URL(), sizes, pi)
+	r.published = pi.full().Name
+	return nil
+}
+
+// Resource returns the first resource whose name matches the glob pattern,
+// or nil if there is none, e.g. {{ with .Resource "cover.*" }}.
+func (e *entry) Resource(pattern string) *resource {
+	for _, r := range e.Resources {
+		if ok, _ := path.Match(pattern, r.Name); ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// ResourcesMatching returns all resources whose names match the glob pattern,
+// e.g. {{ range .ResourcesMatching "*.pdf" }}.
+func (e *entry) ResourcesMatching(pattern string) []*resource {
+	result := []*resource{}
+	for _, r := range e.Resources {
+		if ok, _ := path.Match(pattern, r.Name); ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// ResourcesOfKind returns all resources of the given major MIME type, e.g.
+// "image".
+func (e *entry) ResourcesOfKind(kind string) []*resource {
+	result := []*resource{}
+	for _, r := range e.Resources {
+		if r.Kind == kind {
+			result = append(result, r)
+		}
+	}
+	return result
+}
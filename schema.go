@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// frontMatterSchema is a small subset of JSON schema to validate the front
+// matter of entries.
+type frontMatterSchema struct {
+	Required   []string                   `json:"required"`
+	Properties map[string]*schemaProperty `json:"properties"`
+}
+
+type schemaProperty struct {
+	// Type is one of string, number, integer, boolean, array, object or date.
+	Type    string          `json:"type"`
+	Enum    []interface{}   `json:"enum"`
+	Pattern string          `json:"pattern"`
+	Minimum *float64        `json:"minimum"`
+	Maximum *float64        `json:"maximum"`
+	Items   *schemaProperty `json:"items"`
+
+	pattern *regexp.Regexp
+}
+
+func (s *frontMatterSchema) compile() error {
+	for name, p := range s.Properties {
+		err := p.compile()
+		if err != nil {
+			return fmt.Errorf("property %#v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (p *schemaProperty) compile() error {
+	switch p.Type {
+	case "", "string", "number", "integer", "boolean", "array", "object", "date":
+	default:
+		return fmt.Errorf("unsupported type %#v", p.Type)
+	}
+
+	if p.Pattern != "" {
+		var err error
+		p.pattern, err = regexp.Compile(p.Pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.Items != nil {
+		return p.Items.compile()
+	}
+	return nil
+}
+
+// validate returns all violations rather than stopping at the first.
+func (s *frontMatterSchema) validate(ps params) []string {
+	violations := []string{}
+
+	for _, name := range s.Required {
+		if !ps.Has(name) {
+			violations = append(violations, fmt.Sprintf("%#v is required", name))
+		}
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if v, ok := ps[name]; ok {
+			violations = append(violations, s.Properties[name].validate(name, v)...)
+		}
+	}
+
+	return violations
+}
+
+func (p *schemaProperty) validate(name string, v interface{}) []string {
+	violations := []string{}
+	fail := func(f string, args ...interface{}) []string {
+		return append(violations, fmt.Sprintf("%#v ", name)+fmt.Sprintf(f, args...))
+	}
+
+	if !p.hasType(v) {
+		return fail("is %T but should be of type %s", v, p.Type)
+	}
+
+	if len(p.Enum) > 0 {
+		found := false
+		for _, ev := range p.Enum {
+			if fmt.Sprint(ev) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violations = fail("is %v but should be one of %v", v, p.Enum)
+		}
+	}
+
+	if p.pattern != nil && !p.pattern.MatchString(fmt.Sprint(v)) {
+		violations = fail("is %#v but should match %#v", fmt.Sprint(v), p.Pattern)
+	}
+
+	if n, ok := toFloat(v); ok {
+		if p.Minimum != nil && n < *p.Minimum {
+			violations = fail("is %v but should be at least %v", v, *p.Minimum)
+		}
+		if p.Maximum != nil && n > *p.Maximum {
+			violations = fail("is %v but should be at most %v", v, *p.Maximum)
+		}
+	}
+
+	if items, ok := v.([]interface{}); ok && p.Items != nil {
+		for i, iv := range items {
+			violations = append(violations, p.Items.validate(fmt.Sprintf("%s[%d]", name, i), iv)...)
+		}
+	}
+
+	return violations
+}
+
+func (p *schemaProperty) hasType(v interface{}) bool {
+	switch p.Type {
+	case "":
+		return true
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "integer":
+		n, ok := toFloat(v)
+		return ok && n == float64(int64(n))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "date":
+		_, err := parseDate(v, time.UTC)
+		return err == nil
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
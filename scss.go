@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// there is no pure Go implementation of Sass, so mugo compiles the subset of
+// SCSS that covers plain stylesheets: variables, nested rules with & parent
+// references, nested @media and @supports, // comments and @import of
+// partials. Mixins, functions, control flow and @extend are reported as
+// errors rather than silently producing wrong CSS, arithmetic on values is
+// not evaluated.
+
+var scssImport = regexp.MustCompile(`@import\s+["']([^"']+)["']\s*;`)
+var scssVariable = regexp.MustCompile(`#\{\$([\w-]+)\}|\$([\w-]+)`)
+
+var scssUnsupported = []string{"@mixin", "@include", "@extend", "@function", "@return", "@if", "@else", "@each", "@for", "@while", "@use", "@forward"}
+
+type scssNode struct {
+	text     string // declaration, or prelude of a block
+	block    bool
+	children []*scssNode
+}
+
+type scssParser struct {
+	src string
+	pos int
+}
+
+func compileSCSS(fn string) ([]byte, error) {
+	src, err := readSCSS(fn, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &scssParser{src: src}
+	nodes, err := p.parseBlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %#v: %w", fn, err)
+	}
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("failed to parse %#v: unexpected }", fn)
+	}
+
+	var buf bytes.Buffer
+	err = emitSCSS(&buf, nodes, nil, map[string]string{}, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %#v: %w", fn, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readSCSS strips comments and inlines imported partials, plain css imports
+// are kept for the browser to resolve.
+func readSCSS(fn string, seen map[string]bool) (string, error) {
+	if seen[fn] {
+		return "", fmt.Errorf("import cycle at %#v", fn)
+	}
+	seen[fn] = true
+	defer delete(seen, fn)
+
+	byt, err := os.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	src := stripSCSSComments(string(byt))
+
+	var ierr error
+	src = scssImport.ReplaceAllStringFunc(src, func(stmt string) string {
+		name := scssImport.FindStringSubmatch(stmt)[1]
+		if strings.HasSuffix(name, ".css") || strings.Contains(name, "://") {
+			return stmt
+		}
+		dir, base := filepath.Split(filepath.Join(filepath.Dir(fn), name))
+		for _, c := range []string{base, base + ".scss", "_" + base, "_" + base + ".scss"} {
+			pth := filepath.Join(dir, c)
+			if _, err := os.Stat(pth); err == nil {
+				imported, err := readSCSS(pth, seen)
+				if err != nil {
+					ierr = err
+				}
+				return imported
+			}
+		}
+		ierr = fmt.Errorf("failed to find import %#v in %#v", name, fn)
+		return stmt
+	})
+
+	return src, ierr
+}
+
+// stripSCSSComments removes // and /* */ comments, but not within strings or
+// unquoted urls like url(//cdn.example/font.woff).
+func stripSCSSComments(src string) string {
+	var buf strings.Builder
+	for i := 0; i < len(src); {
+		switch {
+		case src[i] == '"' || src[i] == '\'':
+			end, _ := skipSCSSString(src, i)
+			buf.WriteString(src[i:end])
+			i = end
+		case strings.HasPrefix(src[i:], "url("):
+			// quoted urls are copied as strings in the next iterations.
+			end := i + len("url(")
+			rest := strings.TrimLeft(src[end:], " \t\n")
+			if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+				end = len(src)
+				if j := strings.IndexByte(src[i:], ')'); j >= 0 {
+					end = i + j + 1
+				}
+			}
+			buf.WriteString(src[i:end])
+			i = end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return buf.String()
+			}
+			i += end + 4
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return buf.String()
+			}
+			i += end
+		default:
+			buf.WriteByte(src[i])
+			i++
+		}
+	}
+	return buf.String()
+}
+
+// skipSCSSString returns the index after the string that starts with the
+// quote at src[i], ok is false if the string is not terminated.
+func skipSCSSString(src string, i int) (end int, ok bool) {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case src[i]:
+			return j + 1, true
+		}
+	}
+	return len(src), false
+}
+
+func (p *scssParser) parseBlock() ([]*scssNode, error) {
+	nodes := []*scssNode{}
+	start := p.pos
+	depth := 0
+
+	flush := func() {
+		if text := strings.TrimSpace(p.src[start:p.pos]); text != "" {
+			nodes = append(nodes, &scssNode{text: text})
+		}
+	}
+
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '"', '\'':
+			end, ok := skipSCSSString(p.src, p.pos)
+			if !ok {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			p.pos = end - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ';':
+			if depth == 0 {
+				flush()
+				start = p.pos + 1
+			}
+		case '{':
+			if p.pos > 0 && p.src[p.pos-1] == '#' { // interpolation
+				end := strings.IndexByte(p.src[p.pos:], '}')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated interpolation")
+				}
+				p.pos += end
+				break
+			}
+			prelude := strings.TrimSpace(p.src[start:p.pos])
+			p.pos++
+			children, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.src) {
+				return nil, fmt.Errorf("missing } for %#v", prelude)
+			}
+			nodes = append(nodes, &scssNode{text: prelude, block: true, children: children})
+			start = p.pos + 1
+		case '}':
+			flush()
+			return nodes, nil
+		}
+		p.pos++
+	}
+
+	flush()
+	return nodes, nil
+}
+
+// substituteSCSS replaces variables by their values, within strings only
+// interpolations like #{$name} are replaced.
+func substituteSCSS(s string, vars map[string]string) (string, error) {
+	var err error
+	replace := func(m string) string {
+		sm := scssVariable.FindStringSubmatch(m)
+		name := sm[1] + sm[2]
+		v, ok := vars[name]
+		if !ok {
+			err = fmt.Errorf("undefined variable $%s", name)
+		}
+		return v
+	}
+	interpolate := func(m string) string {
+		if !strings.HasPrefix(m, "#{") {
+			return m
+		}
+		return replace(m)
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); {
+		j := strings.IndexAny(s[i:], `"'`)
+		if j < 0 {
+			buf.WriteString(scssVariable.ReplaceAllStringFunc(s[i:], replace))
+			break
+		}
+		end, _ := skipSCSSString(s, i+j)
+		buf.WriteString(scssVariable.ReplaceAllStringFunc(s[i:i+j], replace))
+		buf.WriteString(scssVariable.ReplaceAllStringFunc(s[i+j:end], interpolate))
+		i = end
+	}
+	return buf.String(), err
+}
+
+func resolveSelectors(parents []string, prelude string) []string {
+	children := strings.Split(prelude, ",")
+	result := []string{}
+	for i, c := range children {
+		children[i] = strings.TrimSpace(c)
+	}
+	if len(parents) == 0 {
+		return children
+	}
+
+	for _, p := range parents {
+		for _, c := range children {
+			if strings.Contains(c, "&") {
+				result = append(result, strings.ReplaceAll(c, "&", p))
+			} else {
+				result = append(result, p+" "+c)
+			}
+		}
+	}
+	return result
+}
+
+// emitSCSS writes the flattened css for the nodes. parents are the resolved
+// selectors of the enclosing rules, raw is set within at-rules like
+// @font-face or @keyframes whose declarations are not wrapped in a rule.
+func emitSCSS(buf *bytes.Buffer, nodes []*scssNode, parents []string, scope map[string]string, raw bool) error {
+	vars := map[string]string{}
+	for k, v := range scope {
+		vars[k] = v
+	}
+
+	decls := []string{}
+	nested := []*scssNode{}
+	for _, n := range nodes {
+		for _, kw := range scssUnsupported {
+			if strings.HasPrefix(n.text, kw) {
+				return fmt.Errorf("%s is not supported", kw)
+			}
+		}
+
+		if n.block {
+			nested = append(nested, n)
+			continue
+		}
+
+		if strings.HasPrefix(n.text, "$") {
+			name, val, _ := strings.Cut(n.text[1:], ":")
+			val = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(val), "!default"))
+			val, err := substituteSCSS(val, vars)
+			if err != nil {
+				return err
+			}
+			vars[strings.TrimSpace(name)] = val
+			continue
+		}
+
+		d, err := substituteSCSS(n.text, vars)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d, "@") && len(parents) == 0 && !raw {
+			fmt.Fprintf(buf, "%s;\n", d)
+			continue
+		}
+		decls = append(decls, d)
+	}
+
+	if len(decls) > 0 {
+		switch {
+		case raw && len(parents) == 0:
+			fmt.Fprintf(buf, "%s;", strings.Join(decls, ";"))
+		case len(parents) == 0:
+			return fmt.Errorf("declaration %#v outside of a rule", decls[0])
+		default:
+			fmt.Fprintf(buf, "%s{%s}\n", strings.Join(parents, ","), strings.Join(decls, ";"))
+		}
+	}
+
+	for _, n := range nested {
+		prelude, err := substituteSCSS(n.text, vars)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case strings.HasPrefix(prelude, "@media"), strings.HasPrefix(prelude, "@supports"):
+			fmt.Fprintf(buf, "%s{\n", prelude)
+			err = emitSCSS(buf, n.children, parents, vars, raw)
+			buf.WriteString("}\n")
+		case strings.HasPrefix(prelude, "@"):
+			fmt.Fprintf(buf, "%s{", prelude)
+			err = emitSCSS(buf, n.children, nil, vars, true)
+			buf.WriteString("}\n")
+		default:
+			err = emitSCSS(buf, n.children, resolveSelectors(parents, prelude), vars, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func compileTestSCSS(t *testing.T, files map[string]string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(fn), 0770)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	css, err := compileSCSS(filepath.Join(dir, "main.scss"))
+	return string(css), err
+}
+
+func TestCompileSCSS(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "plain rule",
+			src:  "a { color: red; }",
+			want: "a{color: red}\n",
+		},
+		{
+			name: "variables",
+			src:  "$c: #333;\n$b: 1px solid $c;\np { border: $b; }",
+			want: "p{border: 1px solid #333}\n",
+		},
+		{
+			name: "default variable",
+			src:  "$c: red !default;\np { color: $c; }",
+			want: "p{color: red}\n",
+		},
+		{
+			name: "nested rules",
+			src:  "nav { margin: 0; ul { padding: 0; } li, a { display: block; } }",
+			want: "nav{margin: 0}\nnav ul{padding: 0}\nnav li,nav a{display: block}\n",
+		},
+		{
+			name: "parent reference",
+			src:  "a { color: red; &:hover { color: blue; } .dark & { color: white; } }",
+			want: "a{color: red}\na:hover{color: blue}\n.dark a{color: white}\n",
+		},
+		{
+			name: "parent reference with multiple parents",
+			src:  "h1, h2 { &.title { margin: 0; } }",
+			want: "h1.title,h2.title{margin: 0}\n",
+		},
+		{
+			name: "nested media query",
+			src:  "$w: 600px;\n.box { width: 100%; @media (min-width: $w) { width: 50%; } }",
+			want: ".box{width: 100%}\n@media (min-width: 600px){\n.box{width: 50%}\n}\n",
+		},
+		{
+			name: "top level media query",
+			src:  "@media print { nav { display: none; } }",
+			want: "@media print{\nnav{display: none}\n}\n",
+		},
+		{
+			name: "font face",
+			src:  "@font-face { font-family: x; src: url(x.woff); }",
+			want: "@font-face{font-family: x;src: url(x.woff);}\n",
+		},
+		{
+			name: "top level at rule",
+			src:  "@charset \"utf-8\";\na { color: red; }",
+			want: "@charset \"utf-8\";\na{color: red}\n",
+		},
+		{
+			name: "interpolation",
+			src:  "$n: main;\n.#{$n}-nav { content: \"#{$n}\"; }",
+			want: ".main-nav{content: \"main\"}\n",
+		},
+		{
+			name: "comments",
+			src:  "/* block\ncomment */\na { // line comment\ncolor: red; /* inline */ }\n// trailing",
+			want: "a{color: red}\n",
+		},
+		{
+			name: "comment markers in strings",
+			src:  "a::after { content: \"a // b /* c */\"; }",
+			want: "a::after{content: \"a // b /* c */\"}\n",
+		},
+		{
+			name: "variables in strings",
+			src:  "a::after { content: \"$x\"; }",
+			want: "a::after{content: \"$x\"}\n",
+		},
+		{
+			name: "escaped quotes in strings",
+			src:  "a::after { content: \"\\\"//\\\"\"; }",
+			want: "a::after{content: \"\\\"//\\\"\"}\n",
+		},
+		{
+			name: "protocol relative urls",
+			src:  "a { background: url( //cdn.example/a.png); } b { background: url(\"//cdn.example/b.png\"); }",
+			want: "a{background: url( //cdn.example/a.png)}\nb{background: url(\"//cdn.example/b.png\")}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := compileTestSCSS(t, map[string]string{"main.scss": tc.src})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileSCSSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "undefined variable", src: "a { color: $nope; }", want: "undefined variable $nope"},
+		{name: "mixin", src: "@mixin m { color: red; }", want: "@mixin is not supported"},
+		{name: "include", src: "a { @include m; }", want: "@include is not supported"},
+		{name: "extend", src: "a { @extend .b; }", want: "@extend is not supported"},
+		{name: "missing brace", src: "a { color: red;", want: "missing }"},
+		{name: "extra brace", src: "a { color: red; } }", want: "unexpected }"},
+		{name: "unterminated string", src: "a { content: \"x; }", want: "unterminated string"},
+		{name: "declaration outside rule", src: "color: red;", want: "outside of a rule"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := compileTestSCSS(t, map[string]string{"main.scss": tc.src})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileSCSSImports(t *testing.T) {
+	got, err := compileTestSCSS(t, map[string]string{
+		"main.scss":          "@import \"vars\";\n@import 'lib/buttons';\n@import \"print.css\";\na { color: $c; }",
+		"_vars.scss":         "$c: red; // the accent\n",
+		"lib/_buttons.scss":  "button { color: $c; }",
+		"lib/unrelated.scss": "p { color: blue; }",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "@import \"print.css\";\nbutton{color: red}\na{color: red}\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, err = compileTestSCSS(t, map[string]string{
+		"main.scss": "@import \"a\";",
+		"_a.scss":   "@import \"main\";",
+	})
+	if err == nil || !strings.Contains(err.Error(), "import cycle") {
+		t.Errorf("got error %v, want import cycle", err)
+	}
+
+	_, err = compileTestSCSS(t, map[string]string{"main.scss": "@import \"missing\";"})
+	if err == nil || !strings.Contains(err.Error(), "failed to find import") {
+		t.Errorf("got error %v, want missing import", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type series struct {
+	Name     string
+	Parts    []*entry
+	Blog     *blog
+	Modified time.Time
+}
+
+func newSeries(b *blog, name string) *series {
+	s := &series{Name: name, Parts: []*entry{}, Blog: b}
+	for _, e := range b.Entries {
+		if e.SeriesName == name {
+			s.Parts = append(s.Parts, e)
+		}
+	}
+	sortByPart(s.Parts)
+	s.Modified = findLatestModified(s.Parts)
+
+	for i, e := range s.Parts {
+		e.Series = s
+		e.SeriesPosition = i + 1
+		e.SeriesTotal = len(s.Parts)
+	}
+
+	return s
+}
+
+// sortByPart orders by series-part where given, parts without series-part
+// follow the numbered ones. Equal parts are ordered by date, oldest first.
+func sortByPart(entries []*entry) {
+	ordered := func(i, j int) bool {
+		a, b := entries[i], entries[j]
+
+		if (a.SeriesPart == 0) != (b.SeriesPart == 0) {
+			return a.SeriesPart != 0
+		}
+		if a.SeriesPart != b.SeriesPart {
+			return a.SeriesPart < b.SeriesPart
+		}
+
+		return a.Posted.Before(b.Posted)
+	}
+	sort.SliceStable(entries, ordered)
+}
+
+func (s *series) Slug() string {
+	return slugify(s.Name)
+}
+
+func (s *series) URL() string {
+	return urlJoin(s.Blog.BaseURL, "series", s.HTMLFileName())
+}
+
+func (s *series) RelativeURL() string {
+	return urlJoin(s.Blog.rootPath(), "series", s.HTMLFileName())
+}
+
+func (s *series) HTMLFileName() string {
+	return fmt.Sprintf("%s.html", s.Slug())
+}
+
+func (s *series) renderIndex() error {
+	var err error
+	var buf bytes.Buffer
+
+	err = s.Blog.templates.Series.ExecuteTemplate(&buf, "series", s)
+	if err != nil {
+		return fmt.Errorf("failed to execute series index template: %w", err)
+	}
+
+	seriesDir := filepath.Join(s.Blog.OutputDirectory, "series")
+	err = os.MkdirAll(seriesDir, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create series directory [%s] err=%w", seriesDir, err)
+	}
+
+	fp := filepath.Join(seriesDir, s.HTMLFileName())
+	err = s.Blog.writeOutput(fp, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write series index file: %w", err)
+	}
+	verbose("rendered index for series %#v to %#v.", s.Name, fp)
+
+	return nil
+}
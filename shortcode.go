@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// shortcodes are written as {{< name key="value" >}} or as a pair
+// {{< name >}}inner{{< /name >}}, name refers to a template file
+// "name.html" in the configured shortcodes directory. A tag without a
+// matching closing tag has no inner content, {{< name />}} never has.
+// Shortcodes in code blocks and spans and in the front matter are left as
+// they are. The inner content is converted from markdown, the output of
+// shortcodes is html that is not parsed as markdown again.
+var shortcodeTag = regexp.MustCompile(`\{\{<\s*(/?)([\w-]+)((?:\s+[\w-]+=(?:"[^"]*"|[^\s"]+))*)\s*(/?)>\}\}`)
+var shortcodeParam = regexp.MustCompile(`([\w-]+)=(?:"([^"]*)"|([^\s"]+))`)
+
+type shortcode struct {
+	Name   string
+	Params map[string]string
+	Inner  template.HTML
+	Entry  *entry
+}
+
+func (s *shortcode) Get(key string) string {
+	return s.Params[key]
+}
+
+func readShortcodes(dir string, funcs template.FuncMap) (*template.Template, error) {
+	result := template.New("shortcodes").Funcs(funcs)
+	if dir == "" {
+		return result, nil
+	}
+
+	fs, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fn := range fs {
+		byt, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read shortcode %#v: %w", fn, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(fn), ".html")
+		_, err = result.New(name).Parse(string(byt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse shortcode %#v: %w", fn, err)
+		}
+	}
+	verbose("read %v shortcodes from %#v.", len(fs), dir)
+
+	return result, nil
+}
+
+func parseShortcodeParams(raw string) map[string]string {
+	result := map[string]string{}
+	for _, m := range shortcodeParam.FindAllStringSubmatch(raw, -1) {
+		if m[2] != "" {
+			result[m[1]] = m[2]
+		} else {
+			result[m[1]] = m[3]
+		}
+	}
+	return result
+}
+
+// shortcodeTagAt is a shortcode tag found in the md source.
+type shortcodeTagAt struct {
+	start, end  int
+	name        string
+	params      string
+	closing     bool
+	selfClosing bool
+}
+
+// findShortcodeTags returns the tags in src outside of the front matter and
+// the code blocks and spans of the parsed document.
+func findShortcodeTags(src []byte, doc ast.Node) []*shortcodeTagAt {
+	code := [][2]int{{0, frontMatterEnd(src)}}
+	addLines := func(lines *text.Segments) {
+		for i := 0; i < lines.Len(); i++ {
+			code = append(code, [2]int{lines.At(i).Start, lines.At(i).Stop})
+		}
+	}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindFencedCodeBlock, ast.KindCodeBlock:
+			addLines(n.Lines())
+			return ast.WalkSkipChildren, nil
+		case ast.KindCodeSpan:
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					code = append(code, [2]int{t.Segment.Start, t.Segment.Stop})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	inCode := func(pos int) bool {
+		for _, c := range code {
+			if pos >= c[0] && pos < c[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	result := []*shortcodeTagAt{}
+	for _, loc := range shortcodeTag.FindAllSubmatchIndex(src, -1) {
+		if inCode(loc[0]) {
+			continue
+		}
+		result = append(result, &shortcodeTagAt{
+			start:       loc[0],
+			end:         loc[1],
+			closing:     loc[3] > loc[2],
+			name:        string(src[loc[4]:loc[5]]),
+			params:      string(src[loc[6]:loc[7]]),
+			selfClosing: loc[9] > loc[8],
+		})
+	}
+	return result
+}
+
+// frontMatterEnd returns the offset after the yaml front matter at the start
+// of src, or 0 if there is none.
+func frontMatterEnd(src []byte) int {
+	if !bytes.HasPrefix(src, []byte("---")) {
+		return 0
+	}
+	if m := frontMatterClose.FindIndex(src[3:]); m != nil {
+		return 3 + m[1]
+	}
+	return 0
+}
+
+var frontMatterClose = regexp.MustCompile(`(?m)^---\s*$`)
+
+// closingShortcodeTag returns the index of the tag that closes tags[i], or -1
+// if there is none. Nested tags of the same name are paired first.
+func closingShortcodeTag(tags []*shortcodeTagAt, i int) int {
+	if tags[i].selfClosing {
+		return -1
+	}
+	depth := 0
+	for j := i + 1; j < len(tags); j++ {
+		t := tags[j]
+		switch {
+		case t.name != tags[i].name || t.selfClosing:
+		case !t.closing:
+			depth++
+		case depth == 0:
+			return j
+		default:
+			depth--
+		}
+	}
+	return -1
+}
+
+// expandShortcodes replaces the shortcodes in the md source by placeholders,
+// doc is the parsed source to find code blocks and spans. The returned
+// replacer substitutes the rendered templates for the placeholders in the
+// converted html, a placeholder that makes up a paragraph replaces the
+// paragraph so that block elements are not nested in it.
+func (e *entry) expandShortcodes(md goldmark.Markdown, src []byte, doc ast.Node) ([]byte, *strings.Replacer, error) {
+	var buf bytes.Buffer
+	rendered := []string{}
+	err := e.renderShortcodes(md, &buf, src, findShortcodeTags(src, doc), 0, len(src), &rendered)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), shortcodeReplacer(rendered, 0), nil
+}
+
+// shortcodePlaceholder is plain text that markdown leaves as it is.
+func shortcodePlaceholder(i int) string {
+	return fmt.Sprintf("MUGOSHORTCODE%dEND", i)
+}
+
+func shortcodeReplacer(rendered []string, from int) *strings.Replacer {
+	pairs := []string{}
+	for i := from; i < len(rendered); i++ {
+		ph := shortcodePlaceholder(i)
+		pairs = append(pairs, "<p>"+ph+"</p>", rendered[i], ph, rendered[i])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// renderShortcodes writes src[from:to] to buf with placeholders for the
+// given tags, which are all within that range, and collects the output of
+// the shortcodes in rendered. The inner content of a shortcode is converted
+// with nested shortcodes expanded before it is passed to the shortcode's
+// template.
+func (e *entry) renderShortcodes(md goldmark.Markdown, buf *bytes.Buffer, src []byte, tags []*shortcodeTagAt, from, to int, rendered *[]string) error {
+	tmpl := e.Blog.templates.Shortcodes
+	pos := from
+
+	for i := 0; i < len(tags); i++ {
+		t := tags[i]
+		buf.Write(src[pos:t.start])
+		if t.closing {
+			return fmt.Errorf("unexpected closing shortcode %#v in %#v", t.name, e.MDFile)
+		}
+
+		sc := &shortcode{
+			Name:   t.name,
+			Params: parseShortcodeParams(t.params),
+			Entry:  e,
+		}
+		pos = t.end
+
+		if j := closingShortcodeTag(tags, i); j >= 0 {
+			nested := len(*rendered)
+			var inner, h bytes.Buffer
+			err := e.renderShortcodes(md, &inner, src, tags[i+1:j], t.end, tags[j].start, rendered)
+			if err != nil {
+				return err
+			}
+			err = md.Convert(inner.Bytes(), &h)
+			if err != nil {
+				return fmt.Errorf("failed to convert inner content of shortcode %#v in %#v: %w", t.name, e.MDFile, err)
+			}
+			sc.Inner = template.HTML(trimParagraph(shortcodeReplacer(*rendered, nested).Replace(h.String())))
+			pos = tags[j].end
+			i = j
+		}
+
+		if tmpl.Lookup(t.name) == nil {
+			return fmt.Errorf("unknown shortcode %#v in %#v", t.name, e.MDFile)
+		}
+
+		var out bytes.Buffer
+		err := tmpl.ExecuteTemplate(&out, t.name, sc)
+		if err != nil {
+			return fmt.Errorf("failed to execute shortcode %#v in %#v: %w", t.name, e.MDFile, err)
+		}
+		buf.WriteString(shortcodePlaceholder(len(*rendered)))
+		*rendered = append(*rendered, out.String())
+	}
+
+	buf.Write(src[pos:to])
+	return nil
+}
@@ -5,18 +5,28 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
 )
 
+// tagCloudSteps is the number of distinct weights in a tag cloud.
+const tagCloudSteps = 10
+
 type tag struct {
 	Name     string
+	Slug     string
 	Entries  []*entry
 	Blog     *blog
 	Modified time.Time
+
+	// Weight is between 1 and tagCloudSteps, relative to the entry counts
+	// of all tags.
+	Weight int
 }
 
 func newTag(b *blog, name string) *tag {
-	t := &tag{Name: name, Entries: []*entry{}, Blog: b}
+	t := &tag{Name: name, Slug: tagSlug(name), Entries: []*entry{}, Blog: b}
 	for _, e := range b.Entries {
 		for _, tn := range e.Tags {
 			if tn == name {
@@ -31,16 +41,27 @@ func newTag(b *blog, name string) *tag {
 	return t
 }
 
+func (t *tag) Count() int {
+	return len(t.Entries)
+}
+
+func (t *tag) Latest() time.Time {
+	if len(t.Entries) == 0 {
+		return time.Time{}
+	}
+	return t.Entries[0].Posted
+}
+
 func (t *tag) URL() string {
 	return urlJoin(t.Blog.BaseURL, "tags", t.HTMLFileName())
 }
 
 func (t *tag) RelativeURL() string {
-	return urlJoin("/", "tags", t.HTMLFileName())
+	return urlJoin(t.Blog.rootPath(), "tags", t.HTMLFileName())
 }
 
 func (t *tag) HTMLFileName() string {
-	return fmt.Sprintf("%s.html", t.Name)
+	return fmt.Sprintf("%s.html", t.Slug)
 }
 
 func (t *tag) renderIndex() error {
@@ -53,13 +74,13 @@ func (t *tag) renderIndex() error {
 	}
 
 	tagDir := filepath.Join(t.Blog.OutputDirectory, "tags")
-	err = os.Mkdir(tagDir, 0770)
-	if !os.IsExist(err) {
+	err = os.MkdirAll(tagDir, 0770)
+	if err != nil {
 		return fmt.Errorf("failed to create tags directory [%s] err=%w", tagDir, err)
 	}
 
 	fp := filepath.Join(tagDir, t.HTMLFileName())
-	err = os.WriteFile(fp, buf.Bytes(), 0644)
+	err = t.Blog.writeOutput(fp, buf.Bytes())
 	if err != nil {
 		return fmt.Errorf("failed to write tag index file: %w", err)
 	}
@@ -67,3 +88,150 @@ func (t *tag) renderIndex() error {
 
 	return nil
 }
+
+// tagSlug identifies a tag case-insensitively and is safe to use in paths.
+func tagSlug(name string) string {
+	sl := slugify(name)
+	if sl == "" {
+		sl = fmt.Sprintf("%x", name)
+	}
+	return sl
+}
+
+func (b *blog) resolveTagAlias(name string) string {
+	sl := tagSlug(name)
+	for from, to := range b.Config.TagAliases {
+		if tagSlug(from) == sl {
+			return to
+		}
+	}
+	return name
+}
+
+// tagSpelling reduces a tag name to what tells tags apart: case, spaces,
+// dashes and underscores don't, symbols like in C++ and C# do.
+func tagSpelling(name string) string {
+	drop := func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}
+	return strings.Map(drop, strings.ToLower(name))
+}
+
+// mergeTags replaces the tags of all entries by the most common spelling
+// among tags with the same slug. Tags that only share their slug, like C++
+// and C#, are reported rather than merged.
+func (b *blog) mergeTags() error {
+	entries := append(append([]*entry{}, b.Entries...), b.DraftEntries...)
+
+	spellings := map[string]map[string]int{}
+	for _, e := range entries {
+		for _, tn := range e.Tags {
+			sl := tagSlug(tn)
+			if spellings[sl] == nil {
+				spellings[sl] = map[string]int{}
+			}
+			spellings[sl][tn]++
+		}
+	}
+
+	names := map[string]string{}
+	for sl, counts := range spellings {
+		for tn, c := range counts {
+			best, ok := names[sl]
+			if ok && tagSpelling(tn) != tagSpelling(best) {
+				x, y := best, tn
+				if y < x {
+					x, y = y, x
+				}
+				return fmt.Errorf("tags %#v and %#v have the same slug %#v, consider a tag alias", x, y, sl)
+			}
+			if !ok || c > counts[best] || (c == counts[best] && tn < best) {
+				names[sl] = tn
+			}
+		}
+	}
+
+	for _, e := range entries {
+		merged := []string{}
+		seen := map[string]struct{}{}
+		for _, tn := range e.Tags {
+			sl := tagSlug(tn)
+			if _, ok := seen[sl]; ok {
+				continue
+			}
+			seen[sl] = struct{}{}
+			merged = append(merged, names[sl])
+		}
+		e.Tags = merged
+	}
+
+	return nil
+}
+
+func weighTags(tags []*tag) {
+	if len(tags) == 0 {
+		return
+	}
+
+	min, max := tags[0].Count(), tags[0].Count()
+	for _, t := range tags {
+		if t.Count() < min {
+			min = t.Count()
+		}
+		if t.Count() > max {
+			max = t.Count()
+		}
+	}
+
+	for _, t := range tags {
+		t.Weight = 1
+		if max > min {
+			t.Weight += (t.Count() - min) * (tagCloudSteps - 1) / (max - min)
+		}
+	}
+}
+
+type tagIndex struct {
+	Tags []*tag
+	Blog *blog
+}
+
+func (ti *tagIndex) URL() string {
+	return urlJoin(ti.Blog.BaseURL, "tags", ti.HTMLFileName())
+}
+
+func (ti *tagIndex) RelativeURL() string {
+	return urlJoin(ti.Blog.rootPath(), "tags", ti.HTMLFileName())
+}
+
+func (ti *tagIndex) HTMLFileName() string {
+	return "index.html"
+}
+
+func (ti *tagIndex) renderIndex() error {
+	var err error
+	var buf bytes.Buffer
+
+	err = ti.Blog.templates.TagIndex.ExecuteTemplate(&buf, "tag-index", ti)
+	if err != nil {
+		return fmt.Errorf("failed to execute tag overview template: %w", err)
+	}
+
+	tagDir := filepath.Join(ti.Blog.OutputDirectory, "tags")
+	err = os.MkdirAll(tagDir, 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create tags directory [%s] err=%w", tagDir, err)
+	}
+
+	fp := filepath.Join(tagDir, ti.HTMLFileName())
+	err = ti.Blog.writeOutput(fp, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write tag overview file: %w", err)
+	}
+	verbose("rendered tag overview to %#v.", fp)
+
+	return nil
+}
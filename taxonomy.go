@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// reservedTaxonomyNames are the front matter keys that mugo interprets itself
+// and the directories it writes other pages to, as a taxonomy's name is used
+// as both.
+var reservedTaxonomyNames = map[string]bool{
+	"archive":         true,
+	"author":          true,
+	"authors":         true,
+	"date":            true,
+	"draft":           true,
+	"gallery":         true,
+	"menu":            true,
+	"series":          true,
+	"series-part":     true,
+	"summary":         true,
+	"tags":            true,
+	"title":           true,
+	"translation-key": true,
+	"type":            true,
+	"updated":         true,
+	"weight":          true,
+}
+
+var taxonomyName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+type taxonomy struct {
+	Name  string
+	Terms []*term
+	Blog  *blog
+
+	config *taxonomyConfig
+}
+
+type term struct {
+	Name     string
+	Slug     string
+	Entries  []*entry
+	Taxonomy *taxonomy
+	Blog     *blog
+	Modified time.Time
+}
+
+func newTaxonomy(b *blog, tc *taxonomyConfig) (*taxonomy, error) {
+	tx := &taxonomy{Name: tc.Name, Terms: []*term{}, Blog: b, config: tc}
+
+	for _, g := range b.Groups {
+		if g.Name == tx.Name {
+			return nil, fmt.Errorf("taxonomy %#v has the same name as the directory of group %#v", tx.Name, g.Name)
+		}
+	}
+
+	terms := map[string]*term{}
+	for _, e := range b.Entries {
+		for _, tn := range e.Taxonomies[tx.Name] {
+			sl := tagSlug(tn)
+			if sl == "index" {
+				return nil, fmt.Errorf("term %#v of taxonomy %#v in %#v is reserved for the list of terms", tn, tx.Name, e.MDFile)
+			}
+			t, ok := terms[sl]
+			if !ok {
+				t = &term{Name: tn, Slug: sl, Entries: []*entry{}, Taxonomy: tx, Blog: b}
+				terms[sl] = t
+				tx.Terms = append(tx.Terms, t)
+			}
+			if n := len(t.Entries); n > 0 && t.Entries[n-1] == e {
+				continue // listed twice in the entry's front matter
+			}
+			t.Entries = append(t.Entries, e)
+		}
+	}
+
+	bySlug := func(i, j int) bool {
+		return tx.Terms[i].Slug < tx.Terms[j].Slug
+	}
+	sort.Slice(tx.Terms, bySlug)
+
+	for _, t := range tx.Terms {
+		sortByDate(t.Entries)
+		t.Modified = findLatestModified(t.Entries)
+	}
+
+	return tx, nil
+}
+
+// Term finds a term by any of its spellings.
+func (tx *taxonomy) Term(name string) *term {
+	sl := tagSlug(name)
+	for _, t := range tx.Terms {
+		if t.Slug == sl {
+			return t
+		}
+	}
+	return nil
+}
+
+func (tx *taxonomy) URL() string {
+	return urlJoin(tx.Blog.BaseURL, tx.Name, tx.HTMLFileName())
+}
+
+func (tx *taxonomy) RelativeURL() string {
+	return urlJoin(tx.Blog.rootPath(), tx.Name, tx.HTMLFileName())
+}
+
+func (tx *taxonomy) HTMLFileName() string {
+	return "index.html"
+}
+
+func (tx *taxonomy) dir() string {
+	return filepath.Join(tx.Blog.OutputDirectory, tx.Name)
+}
+
+func (tx *taxonomy) render() error {
+	var err error
+	var buf bytes.Buffer
+
+	err = tx.Blog.templates.Taxonomy.ExecuteTemplate(&buf, "taxonomy", tx)
+	if err != nil {
+		return fmt.Errorf("failed to execute taxonomy template: %w", err)
+	}
+
+	err = os.MkdirAll(tx.dir(), 0770)
+	if err != nil {
+		return fmt.Errorf("failed to create taxonomy directory [%s] err=%w", tx.dir(), err)
+	}
+
+	fp := filepath.Join(tx.dir(), tx.HTMLFileName())
+	err = tx.Blog.writeOutput(fp, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write taxonomy file: %w", err)
+	}
+	verbose("rendered taxonomy %#v to %#v.", tx.Name, fp)
+
+	for _, t := range tx.Terms {
+		err = t.renderIndex()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *term) URL() string {
+	return urlJoin(t.Blog.BaseURL, t.Taxonomy.Name, t.HTMLFileName())
+}
+
+func (t *term) RelativeURL() string {
+	return urlJoin(t.Blog.rootPath(), t.Taxonomy.Name, t.HTMLFileName())
+}
+
+func (t *term) HTMLFileName() string {
+	return fmt.Sprintf("%s.html", t.Slug)
+}
+
+func (t *term) renderIndex() error {
+	var err error
+	var buf bytes.Buffer
+
+	err = t.Blog.templates.Term.ExecuteTemplate(&buf, "term", t)
+	if err != nil {
+		return fmt.Errorf("failed to execute term template: %w", err)
+	}
+
+	fp := filepath.Join(t.Taxonomy.dir(), t.HTMLFileName())
+	err = t.Blog.writeOutput(fp, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write term file: %w", err)
+	}
+	verbose("rendered term %#v of %#v to %#v.", t.Name, t.Taxonomy.Name, fp)
+
+	fc := t.Blog.Config.Feed
+	if fc == nil || !t.Taxonomy.config.Feed {
+		return nil
+	}
+
+	title := fmt.Sprintf("%s: %s", fc.Title, t.Name)
+	fd := newFeed(fc, title, t.URL(), latest(t.Entries, feedEntryCount))
+	return t.Blog.writeFeeds(fc, fd, t.Taxonomy.dir(), t.Slug)
+}
@@ -8,29 +8,56 @@ import (
 )
 
 type templates struct {
-	Main  *template.Template
-	Top   *template.Template
-	Group *template.Template
-	Tags  *template.Template
-	Entry *template.Template
+	Main     *template.Template
+	Top      *template.Template
+	Group    *template.Template
+	Tags     *template.Template
+	Entry    *template.Template
+	Series   *template.Template
+	Archive  *template.Template
+	TagIndex *template.Template
+	Taxonomy *template.Template
+	Term     *template.Template
+	Author   *template.Template
+
+	Shortcodes *template.Template
 }
 
-func NowLayout(l string) string {
-	return time.Now().Format(l)
+func NowLayout(loc *time.Location, l string) string {
+	return time.Now().In(loc).Format(l)
 }
 
-func NowFormatted() string {
-	return time.Now().Format(time.RFC3339)
+func NowFormatted(loc *time.Location) string {
+	return time.Now().In(loc).Format(time.RFC3339)
 }
 
-func createTemplate(name, file, fallback string) (*template.Template, error) {
-	var raw string
+// templateFuncs formats times in the configured timezone, and LocalDate,
+// MonthName and WeekdayName use the names of the configured language.
+func (b *blog) templateFuncs() template.FuncMap {
+	loc := b.Config.Location()
+	lcl := b.Config.Locale()
 	funcs := template.FuncMap{
-		"FormatDate": FormatDate,
-		"TimeLayout": TimeLayout,
-		"Now":        NowFormatted,
-		"NowLayout":  NowLayout,
+		"FormatDate": func(t time.Time) string { return FormatDate(t.In(loc)) },
+		"TimeLayout": func(t time.Time, l string) string { return TimeLayout(t.In(loc), l) },
+		"Now":        func() string { return NowFormatted(loc) },
+		"NowLayout":  func(l string) string { return NowLayout(loc, l) },
+
+		"LocalDate":   func(t time.Time, l string) string { return lcl.format(t.In(loc), l) },
+		"MonthName":   func(t time.Time) string { return lcl.MonthName(t.In(loc)) },
+		"WeekdayName": func(t time.Time) string { return lcl.WeekdayName(t.In(loc)) },
+
+		"asset":          b.Asset,
+		"assetIntegrity": b.AssetIntegrity,
 	}
+
+	for n, f := range b.libraryFuncs() {
+		funcs[n] = f
+	}
+	return funcs
+}
+
+func createTemplate(name, file, fallback string, funcs template.FuncMap) (*template.Template, error) {
+	var raw string
 	if file == "" {
 		raw = fallback
 		verbose("template %#v uses fallback rather than file: %#v", name, file)
@@ -45,35 +72,70 @@ func createTemplate(name, file, fallback string) (*template.Template, error) {
 	return template.New(name).Funcs(funcs).Parse(raw)
 }
 
-func readTemplates(cfg *templatesConfig) (*templates, error) {
+func readTemplates(cfg *templatesConfig, funcs template.FuncMap) (*templates, error) {
 	var err error
 	result := &templates{}
 
-	result.Main, err = createTemplate("main", cfg.Main, tmplMain)
+	result.Main, err = createTemplate("main", cfg.Main, tmplMain, funcs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse main template: %w", err)
 	}
 
-	result.Top, err = createTemplate("top", cfg.Top, tmplTop)
+	result.Top, err = createTemplate("top", cfg.Top, tmplTop, funcs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse top template: %w", err)
 	}
 
-	result.Group, err = createTemplate("group", cfg.Group, tmplGroup)
+	result.Group, err = createTemplate("group", cfg.Group, tmplGroup, funcs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse group template: %w", err)
 	}
 
-	result.Tags, err = createTemplate("tags", cfg.Tags, tmplTags)
+	result.Tags, err = createTemplate("tags", cfg.Tags, tmplTags, funcs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse tags template: %w", err)
 	}
 
-	result.Entry, err = createTemplate("entry", cfg.Entry, tmplEntry)
+	result.Entry, err = createTemplate("entry", cfg.Entry, tmplEntry, funcs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse entry template: %w", err)
 	}
 
+	result.Series, err = createTemplate("series", cfg.Series, tmplSeries, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse series template: %w", err)
+	}
+
+	result.Archive, err = createTemplate("archive", cfg.Archive, tmplArchive, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archive template: %w", err)
+	}
+
+	result.TagIndex, err = createTemplate("tag-index", cfg.TagIndex, tmplTagIndex, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tag-index template: %w", err)
+	}
+
+	result.Taxonomy, err = createTemplate("taxonomy", cfg.Taxonomy, tmplTaxonomy, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse taxonomy template: %w", err)
+	}
+
+	result.Term, err = createTemplate("term", cfg.Term, tmplTerm, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse term template: %w", err)
+	}
+
+	result.Author, err = createTemplate("author", cfg.Author, tmplAuthor, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse author template: %w", err)
+	}
+
+	result.Shortcodes, err = readShortcodes(cfg.Shortcodes, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shortcodes: %w", err)
+	}
+
 	return result, nil
 }
 
@@ -335,3 +397,261 @@ var tmplEntry = `
   </body>
 </html>
 `
+
+var tmplSeries = `
+<!doctype html>
+<html>
+<meta charset="UTF-8">
+  <head>
+    <title>{{ .Name }}</title>
+    <link rel="stylesheet" type="text/css" href="../style.css">
+  </head>
+
+  <body>
+
+    <section>
+
+      <h1>{{ .Name }}</h1>
+
+      <ol>
+      {{ range .Parts }}
+        <li>
+          <a href="{{ .RelativeURL }}">{{ .Title }}</a>
+          posted on {{ FormatDate .Posted }}
+        </li>
+      {{ end }}
+      </ol>
+
+    </section>
+
+    <footer>
+      <div>
+        <a href="../index.html">{{ .Blog.Title }}</a> /
+        {{ .Name }}
+      </div>
+      <div>
+        {{ len .Parts }} parts
+      </div>
+    </footer>
+
+  </body>
+</html>
+`
+
+var tmplArchive = `
+<!doctype html>
+<html>
+<meta charset="UTF-8">
+  <head>
+    <title>{{ .Name }}</title>
+    <link rel="stylesheet" type="text/css" href="{{ .RelativeRoot }}style.css">
+  </head>
+
+  <body>
+
+    <section>
+
+      <h1>{{ .Name }}</h1>
+
+      {{ if .IsMonth }}
+      {{ range .Entries }}
+      <article>
+        <div>
+          <a href="{{ .RelativeURL }}"><h2>{{ .Title }}</h2></a>
+        </div>
+        <div>
+          posted on {{ FormatDate .Posted }}
+        </div>
+      </article>
+      {{ end }}
+      {{ else }}
+      <ul>
+      {{ range .Archives }}
+        <li><a href="{{ .RelativeURL }}">{{ .Name }}</a> ({{ .Count }})</li>
+      {{ end }}
+      </ul>
+      {{ end }}
+
+    </section>
+
+    <footer>
+      <div>
+        <a href="{{ .RelativeRoot }}index.html">{{ .Blog.Title }}</a> /
+        {{ .Name }}
+      </div>
+      <div>
+        {{ .Count }} entries
+      </div>
+    </footer>
+
+  </body>
+</html>
+`
+
+var tmplTagIndex = `
+<!doctype html>
+<html>
+<meta charset="UTF-8">
+  <head>
+    <title>tags</title>
+    <link rel="stylesheet" type="text/css" href="../style.css">
+  </head>
+
+  <body>
+
+    <section>
+
+      <h1>tags</h1>
+
+      <ul class="tag-cloud">
+      {{ range .Tags }}
+        <li class="weight-{{ .Weight }}">
+          <a href="{{ .HTMLFileName }}">{{ .Name }}</a> ({{ .Count }}),
+          latest on {{ FormatDate .Latest }}
+        </li>
+      {{ end }}
+      </ul>
+
+    </section>
+
+    <footer>
+      <div>
+        <a href="../index.html">{{ .Blog.Title }}</a> /
+        tags
+      </div>
+      <div>
+        {{ len .Tags }} tags
+      </div>
+    </footer>
+
+  </body>
+</html>
+`
+
+var tmplTaxonomy = `
+<!doctype html>
+<html>
+<meta charset="UTF-8">
+  <head>
+    <title>{{ .Name }}</title>
+    <link rel="stylesheet" type="text/css" href="../style.css">
+  </head>
+
+  <body>
+
+    <section>
+
+      <h1>{{ .Name }}</h1>
+
+      <ul>
+      {{ range .Terms }}
+        <li>
+          <a href="{{ .HTMLFileName }}">{{ .Name }}</a> ({{ len .Entries }})
+        </li>
+      {{ end }}
+      </ul>
+
+    </section>
+
+    <footer>
+      <div>
+        <a href="../index.html">{{ .Blog.Title }}</a> /
+        {{ .Name }}
+      </div>
+    </footer>
+
+  </body>
+</html>
+`
+
+var tmplTerm = `
+<!doctype html>
+<html>
+<meta charset="UTF-8">
+  <head>
+    <title>{{ .Name }}</title>
+    <link rel="stylesheet" type="text/css" href="../style.css">
+  </head>
+
+  <body>
+
+    <section>
+
+      <h1>{{ .Name }}</h1>
+
+      {{ range .Entries }}
+      <article>
+        <div>
+          <a href="{{ .RelativeURL }}"><h2>{{ .Title }}</h2></a>
+        </div>
+        <div>
+          posted on {{ FormatDate .Posted }}
+        </div>
+      </article>
+      {{ end }}
+
+    </section>
+
+    <footer>
+      <div>
+        <a href="../index.html">{{ .Blog.Title }}</a> /
+        <a href="index.html">{{ .Taxonomy.Name }}</a> /
+        {{ .Name }}
+      </div>
+      <div>
+        {{ len .Entries }} entries
+      </div>
+    </footer>
+
+  </body>
+</html>
+`
+
+var tmplAuthor = `
+<!doctype html>
+<html>
+<meta charset="UTF-8">
+  <head>
+    <title>{{ .Name }}</title>
+    <link rel="stylesheet" type="text/css" href="../style.css">
+  </head>
+
+  <body>
+
+    <section>
+
+      {{ with .Avatar }}<img src="{{ . }}" alt="">{{ end }}
+      <h1>{{ .Name }}</h1>
+      <p>{{ .Bio }}</p>
+      <ul>
+      {{ range $name, $href := .Links }}
+        <li><a href="{{ $href }}">{{ $name }}</a></li>
+      {{ end }}
+      </ul>
+
+      {{ range .Entries }}
+      <article>
+        <div>
+          <a href="{{ .RelativeURL }}"><h2>{{ .Title }}</h2></a>
+        </div>
+        <div>
+          posted on {{ FormatDate .Posted }}
+        </div>
+      </article>
+      {{ end }}
+
+    </section>
+
+    <footer>
+      <div>
+        <a href="../index.html">{{ .Blog.Title }}</a> /
+        {{ .Name }}
+      </div>
+      <div>
+        {{ len .Entries }} entries
+      </div>
+    </footer>
+
+  </body>
+</html>
+`
@@ -6,7 +6,10 @@ import (
 	"html/template"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/fgeller/relabs"
@@ -15,6 +18,7 @@ import (
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 )
 
 type top struct {
@@ -23,6 +27,8 @@ type top struct {
 
 	Title    string
 	Modified time.Time
+	Params   params
+	Weight   int
 
 	RenderedHTML template.HTML
 
@@ -58,17 +64,44 @@ func (t *top) readModified() error {
 	}
 
 	t.Modified = st.ModTime()
+	if gt, ok := t.Blog.gitModTime(t.MDFile); ok {
+		t.Modified = gt
+	}
 
 	return nil
 }
 
+func (t *top) URL() string {
+	return urlJoin(t.Blog.BaseURL, t.relativePath())
+}
+
+func (t *top) RelativeURL() string {
+	return urlJoin(t.Blog.rootPath(), t.relativePath())
+}
+
+func (t *top) relativePath() string {
+	rel, err := filepath.Rel(t.Blog.OutputDirectory, t.HTMLFile)
+	if err != nil {
+		return filepath.Base(t.HTMLFile)
+	}
+	return filepath.ToSlash(rel)
+}
+
 func (t *top) BaseURL() (*url.URL, error) {
-	raw := urlJoin(t.Blog.BaseURL, t.Dir()) + "/"
+	raw := t.Blog.BaseURL
+	if dir := t.Dir(); dir != "." {
+		raw = urlJoin(raw, dir)
+	}
+	if !strings.HasSuffix(raw, "/") {
+		raw += "/"
+	}
 	return url.Parse(raw)
 }
 
+// Dir is the directory of the page relative to the output directory, "." for
+// pages directly in the base directory.
 func (t *top) Dir() string {
-	return filepath.Base(filepath.Dir(t.MDFile))
+	return path.Dir(t.relativePath())
 }
 
 func (t *top) readMD() error {
@@ -105,6 +138,11 @@ func (t *top) readMD() error {
 	}
 	t.RenderedHTML = template.HTML(buf.String())
 
+	t.RenderedHTML, err = t.Blog.responsiveImages(t.RenderedHTML, t.MDFile)
+	if err != nil {
+		return err
+	}
+
 	err = t.parseHeader(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to parse header: %w", err)
@@ -117,11 +155,23 @@ func (t *top) parseHeader(ctx parser.Context) error {
 	header := meta.Get(ctx)
 	var ok bool
 
+	t.Params = newParams(header)
+
 	t.Title, ok = header["title"].(string)
 	if !ok {
 		return fmt.Errorf("title is missing in %#v", t.MDFile)
 	}
 
+	t.Weight = t.Params.Int("weight")
+
+	if rawUpdated, exists := header["updated"]; exists {
+		var err error
+		t.Modified, err = parseDate(rawUpdated, t.Blog.Config.Location())
+		if err != nil {
+			return fmt.Errorf("failed to parse header updated in %#v: %w", t.MDFile, err)
+		}
+	}
+
 	return nil
 }
 
@@ -134,7 +184,12 @@ func (t *top) writeHTML() error {
 		return fmt.Errorf("failed to execute top template: %w", err)
 	}
 
-	err = os.WriteFile(t.HTMLFile, buf.Bytes(), 0644)
+	err = os.MkdirAll(filepath.Dir(t.HTMLFile), 0770)
+	if err != nil {
+		return err
+	}
+
+	err = t.Blog.writeOutput(t.HTMLFile, buf.Bytes())
 	if err != nil {
 		return err
 	}
@@ -142,3 +197,38 @@ func (t *top) writeHTML() error {
 
 	return nil
 }
+
+func sortTops(tops []*top) {
+	byWeight := func(i, j int) bool {
+		if tops[i].Weight != tops[j].Weight {
+			return tops[i].Weight < tops[j].Weight
+		}
+		return tops[i].Title < tops[j].Title
+	}
+	sort.SliceStable(tops, byWeight)
+}
+
+// isPage reports whether a markdown file below the base directory is a static
+// page rather than a dated entry: either it is located in one of the
+// configured page directories or its front matter sets "type: page".
+func (b *blog) isPage(md string) (bool, error) {
+	for _, dir := range b.Config.PageDirectories {
+		rel, err := filepath.Rel(filepath.Join(b.BaseDirectory, dir), md)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return true, nil
+		}
+	}
+
+	src, err := os.ReadFile(md)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := parser.NewContext()
+	goldmark.New(goldmark.WithExtensions(meta.Meta)).
+		Parser().
+		Parse(text.NewReader(src), parser.WithContext(ctx))
+	tp, _ := meta.Get(ctx)["type"].(string)
+
+	return tp == "page", nil
+}